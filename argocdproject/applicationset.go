@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application"
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	applicationSetGeneratorList = "list"
+	applicationSetGeneratorGit  = "git"
+)
+
+type ApplicationSetOutput struct {
+	Name      string                              `json:"name,omitempty"`
+	Generator string                              `json:"generator,omitempty"`
+	Git       *argov1alpha1.GitGenerator          `json:"git,omitempty"`
+	Template  argov1alpha1.ApplicationSetTemplate `json:"template,omitempty"`
+}
+
+func (o *ApplicationSetOutput) RepoURLs() []string {
+	var repoURLs []string
+
+	if o.Git != nil && o.Git.RepoURL != "" {
+		repoURLs = append(repoURLs, o.Git.RepoURL)
+	}
+
+	for _, source := range o.Template.Spec.GetSources() {
+		if source.RepoURL != "" {
+			repoURLs = append(repoURLs, source.RepoURL)
+		}
+	}
+
+	return repoURLs
+}
+
+// Destination returns the destination of the generated applications when every one of them shares the template's,
+// which is only the case with the git generator.
+func (o *ApplicationSetOutput) Destination() *argov1alpha1.ApplicationDestination {
+	if o.Generator != applicationSetGeneratorGit {
+		return nil
+	}
+
+	return &o.Template.Spec.Destination
+}
+
+func isTemplatedDestination(destination *argov1alpha1.ApplicationDestination) bool {
+	return isGoTemplate(destination.Server) || isGoTemplate(destination.Name) || isGoTemplate(destination.Namespace)
+}
+
+func isGoTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+type listGeneratorElement struct {
+	Name                 string `json:"name"`
+	RepoURL              string `json:"repoURL"`
+	Path                 string `json:"path"`
+	TargetRevision       string `json:"targetRevision"`
+	Chart                string `json:"chart"`
+	DestinationServer    string `json:"destinationServer"`
+	DestinationName      string `json:"destinationName"`
+	DestinationNamespace string `json:"destinationNamespace"`
+}
+
+func makeApplicationSet(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) ([]byte, error) {
+	applicationSetOutput := argocdProject.Spec.ApplicationSet
+	template := applicationSetOutput.Template
+
+	var generator argov1alpha1.ApplicationSetGenerator
+	switch applicationSetOutput.Generator {
+	case "", applicationSetGeneratorList:
+		listGenerator, err := makeListGenerator(argocdProject, environmentProfile, &template)
+		if err != nil {
+			return nil, err
+		}
+		generator.List = listGenerator
+
+	case applicationSetGeneratorGit:
+		gitGenerator, err := makeGitGenerator(argocdProject, environmentProfile, &template)
+		if err != nil {
+			return nil, err
+		}
+		generator.Git = gitGenerator
+
+	default:
+		return nil, fmt.Errorf("unknown application set generator %s", applicationSetOutput.Generator)
+	}
+
+	name := applicationSetOutput.Name
+	if name == "" {
+		name = argocdProject.Name
+	}
+
+	applicationSet := &argov1alpha1.ApplicationSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: argov1alpha1.SchemeGroupVersion.String(),
+			Kind:       application.ApplicationSetKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: argocdProject.Spec.ApplicationNamespace,
+		},
+		Spec: argov1alpha1.ApplicationSetSpec{
+			GoTemplate: true,
+			GoTemplateOptions: []string{
+				conventionOption,
+			},
+			Generators: []argov1alpha1.ApplicationSetGenerator{
+				generator,
+			},
+			Template: template,
+		},
+	}
+
+	return marshalYAMLWithoutStatusField(applicationSet)
+}
+
+func makeListGenerator(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile, template *argov1alpha1.ApplicationSetTemplate) (*argov1alpha1.ListGenerator, error) {
+	apps := argocdProject.Spec.ApplicationTemplates
+	if err := prepareApplications(argocdProject, environmentProfile, apps); err != nil {
+		return nil, err
+	}
+
+	elements := make([]apiextensionsv1.JSON, 0, len(apps))
+	for _, app := range apps {
+		source := app.Spec.GetSource()
+
+		raw, err := json.Marshal(listGeneratorElement{
+			Name:                 app.Name,
+			RepoURL:              source.RepoURL,
+			Path:                 source.Path,
+			TargetRevision:       source.TargetRevision,
+			Chart:                source.Chart,
+			DestinationServer:    app.Spec.Destination.Server,
+			DestinationName:      app.Spec.Destination.Name,
+			DestinationNamespace: app.Spec.Destination.Namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, apiextensionsv1.JSON{
+			Raw: raw,
+		})
+	}
+
+	template.Name = "{{ .name }}"
+	template.Spec.Project = argocdProject.Name
+
+	makeApplicationDefaults(argocdProject, environmentProfile).apply(&template.Finalizers, &template.Spec)
+	applyNotificationAnnotations(&template.Annotations, makeNotificationAnnotations(argocdProject, environmentProfile))
+
+	if template.Spec.Source == nil {
+		template.Spec.Source = &argov1alpha1.ApplicationSource{}
+	}
+	template.Spec.Source.RepoURL = "{{ .repoURL }}"
+	template.Spec.Source.Path = "{{ .path }}"
+	template.Spec.Source.TargetRevision = "{{ .targetRevision }}"
+	template.Spec.Source.Chart = "{{ .chart }}"
+
+	template.Spec.Destination = argov1alpha1.ApplicationDestination{
+		Server:    "{{ .destinationServer }}",
+		Name:      "{{ .destinationName }}",
+		Namespace: "{{ .destinationNamespace }}",
+	}
+
+	return &argov1alpha1.ListGenerator{
+		Elements: elements,
+	}, nil
+}
+
+func makeGitGenerator(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile, template *argov1alpha1.ApplicationSetTemplate) (*argov1alpha1.GitGenerator, error) {
+	gitGenerator := *argocdProject.Spec.ApplicationSet.Git
+
+	apps := []argov1alpha1.Application{
+		argov1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        template.Name,
+				Annotations: template.Annotations,
+				Finalizers:  template.Finalizers,
+			},
+			Spec: template.Spec,
+		},
+	}
+	if err := prepareApplications(argocdProject, environmentProfile, apps); err != nil {
+		return nil, err
+	}
+	template.Annotations = apps[0].Annotations
+	template.Finalizers = apps[0].Finalizers
+	template.Spec = apps[0].Spec
+
+	source := template.Spec.GetSource()
+	if gitGenerator.RepoURL == "" {
+		gitGenerator.RepoURL = source.RepoURL
+	}
+	if gitGenerator.Revision == "" {
+		gitGenerator.Revision = source.TargetRevision
+	}
+
+	return &gitGenerator, nil
+}
@@ -6,6 +6,11 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application"
 	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
@@ -14,12 +19,24 @@ import (
 )
 
 const (
-	separatorPanic = ": "
+	separatorError = ": "
 	separatorYaml  = "---\n"
 
-	stagingEnvironment = "staging"
+	anySourceRepo = "*"
+
+	policyResourceAll          = "*"
+	policyResourceApplications = "applications"
+
+	policyActionGet  = "get"
+	policyActionSync = "sync"
 
 	yamlStatusField = "status"
+
+	environmentSourceAnnotation = "argocdproject.incognia.com/environment-source"
+
+	defaultPathConvention           = "./k8s/overlays/{{ .Environment }}"
+	defaultTargetRevisionConvention = "env-{{ .Environment }}"
+	conventionOption                = "missingkey=error"
 )
 
 type accessLevel int
@@ -27,6 +44,7 @@ type accessLevel int
 const (
 	ReadOnly accessLevel = iota
 	ReadSync
+	CISync
 )
 
 func (a accessLevel) String() string {
@@ -35,50 +53,93 @@ func (a accessLevel) String() string {
 		return "read-only"
 	case ReadSync:
 		return "read-sync"
+	case CISync:
+		return "ci-sync"
 	default:
 		panic(fmt.Sprintf("unknown access level %d", a))
 	}
 }
 
-func (a accessLevel) Policies(appProjectName string, environment string) []string {
+func (a accessLevel) Policies(appProjectName string, applicationNamespace string) []string {
 	switch a {
 	case ReadOnly:
 		return []string{
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, *, get, %[1]s/*, allow", appProjectName, ReadOnly),
+			makePolicy(appProjectName, applicationNamespace, ReadOnly.String(), policyResourceAll, policyActionGet),
 		}
 
 	case ReadSync:
-		defaultPolicies := []string{
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/apps/Deployment/restart, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/argoproj.io/Rollout/abort, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/argoproj.io/Rollout/promote-full, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/argoproj.io/Rollout/restart, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/argoproj.io/Rollout/resume, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, action/argoproj.io/Rollout/retry, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, sync, %[1]s/*, allow", appProjectName, ReadSync),
-			fmt.Sprintf("g, proj:%[1]s:%[2]s, proj:%[1]s:%[3]s", appProjectName, ReadSync, ReadOnly),
+		return []string{
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/apps/Deployment/restart"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/argoproj.io/Rollout/abort"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/argoproj.io/Rollout/promote-full"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/argoproj.io/Rollout/restart"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/argoproj.io/Rollout/resume"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, "action/argoproj.io/Rollout/retry"),
+			makePolicy(appProjectName, applicationNamespace, ReadSync.String(), policyResourceApplications, policyActionSync),
+			makeGroupPolicy(appProjectName, ReadSync.String(), ReadOnly.String()),
 		}
-		if environment == stagingEnvironment {
-			defaultPolicies = append(defaultPolicies, fmt.Sprintf("p, proj:%[1]s:%[2]s, applications, override, %[1]s/*, allow", appProjectName, ReadSync))
+
+	case CISync:
+		return []string{
+			makePolicy(appProjectName, applicationNamespace, CISync.String(), policyResourceApplications, policyActionGet),
+			makePolicy(appProjectName, applicationNamespace, CISync.String(), policyResourceApplications, policyActionSync),
 		}
-		return defaultPolicies
 
 	default:
 		panic(fmt.Sprintf("unknown access level %d", a))
 	}
 }
 
+func makePolicy(appProjectName string, applicationNamespace string, roleName string, resource string, action string) string {
+	object := appProjectName + "/*"
+	if applicationNamespace != "" {
+		object = appProjectName + "/" + applicationNamespace + "/*"
+	}
+
+	return fmt.Sprintf("p, proj:%s:%s, %s, %s, %s, allow", appProjectName, roleName, resource, action, object)
+}
+
+func makeGroupPolicy(appProjectName string, roleName string, parentRoleName string) string {
+	return fmt.Sprintf("g, proj:%[1]s:%[2]s, proj:%[1]s:%[3]s", appProjectName, roleName, parentRoleName)
+}
+
 type ArgoCDProject struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	Spec              ProjectSpec `json:"spec,omitempty"`
+
+	environmentSuffix string
+}
+
+func (p *ArgoCDProject) baseName() string {
+	return strings.TrimSuffix(p.Name, p.environmentSuffix)
 }
 
 type ProjectSpec struct {
-	AccessControl        AppProjectAccessControl    `json:"accessControl,omitempty"`
-	Environment          string                     `json:"environment,omitempty"`
-	AppProject           argov1alpha1.AppProject    `json:"appProjectTemplate,omitempty"`
-	ApplicationTemplates []argov1alpha1.Application `json:"applicationTemplates,omitempty"`
+	AccessControl           AppProjectAccessControl                        `json:"accessControl,omitempty"`
+	AccessRoles             []AccessRole                                   `json:"accessRoles,omitempty"`
+	CIRole                  *CIRole                                        `json:"ciRole,omitempty"`
+	ApplicationNamespace    string                                         `json:"applicationNamespace,omitempty"`
+	Environment             string                                         `json:"environment,omitempty"`
+	Environments            []ProjectEnvironment                           `json:"environments,omitempty"`
+	EnvironmentProfiles     []EnvironmentProfile                           `json:"environmentProfiles,omitempty"`
+	EnvironmentProfilesFile string                                         `json:"environmentProfilesFile,omitempty"`
+	ClusterCatalogFile      string                                         `json:"clusterCatalogFile,omitempty"`
+	ApplicationDefaults     ApplicationDefaults                            `json:"applicationDefaults,omitempty"`
+	Conventions             SourceConventions                              `json:"conventions,omitempty"`
+	ResourcePresets         []string                                       `json:"resourcePresets,omitempty"`
+	SyncWindows             []SyncWindow                                   `json:"syncWindows,omitempty"`
+	OrphanedResources       *argov1alpha1.OrphanedResourcesMonitorSettings `json:"orphanedResources,omitempty"`
+	SignatureKeys           []string                                       `json:"signatureKeys,omitempty"`
+	Notifications           []Notification                                 `json:"notifications,omitempty"`
+	NamespaceMetadata       *NamespaceMetadata                             `json:"namespaceMetadata,omitempty"`
+	AllowAnySourceRepo      bool                                           `json:"allowAnySourceRepo,omitempty"`
+	AppProject              argov1alpha1.AppProject                        `json:"appProjectTemplate,omitempty"`
+	MergeTemplateRoles      bool                                           `json:"mergeTemplateRoles,omitempty"`
+	ApplicationTemplates    []argov1alpha1.Application                     `json:"applicationTemplates,omitempty"`
+	ApplicationSet          *ApplicationSetOutput                          `json:"applicationSet,omitempty"`
+	RBACConfigMap           *RBACConfigMapOutput                           `json:"rbacConfigMap,omitempty"`
+	ParentApplication       *ParentApplicationOutput                       `json:"parentApplication,omitempty"`
 }
 
 type AppProjectAccessControl struct {
@@ -86,16 +147,93 @@ type AppProjectAccessControl struct {
 	ReadSync []string `json:"ReadSync,omitempty"`
 }
 
+type SourceConventions struct {
+	Path                   string `json:"path,omitempty"`
+	TargetRevision         string `json:"targetRevision,omitempty"`
+	PreserveTargetRevision bool   `json:"preserveTargetRevision,omitempty"`
+}
+
+type conventionData struct {
+	AppName     string
+	ProjectName string
+	Environment string
+}
+
+type AccessRole struct {
+	Name        string             `json:"name,omitempty"`
+	Groups      []string           `json:"groups,omitempty"`
+	Inherits    []string           `json:"inherits,omitempty"`
+	Permissions []AccessPermission `json:"permissions,omitempty"`
+}
+
+func (r *AccessRole) Policies(appProjectName string, applicationNamespace string) []string {
+	var policies []string
+
+	for _, permission := range r.Permissions {
+		resource := permission.Resource
+		if resource == "" {
+			resource = policyResourceApplications
+		}
+
+		for _, action := range permission.Actions {
+			policies = append(policies, makePolicy(appProjectName, applicationNamespace, r.Name, resource, action))
+		}
+	}
+
+	for _, parentRoleName := range r.Inherits {
+		policies = append(policies, makeGroupPolicy(appProjectName, r.Name, parentRoleName))
+	}
+
+	return policies
+}
+
+type AccessPermission struct {
+	Resource string   `json:"resource,omitempty"`
+	Actions  []string `json:"actions,omitempty"`
+}
+
+type CIRole struct {
+	JWTTokens []argov1alpha1.JWTToken `json:"jwtTokens,omitempty"`
+}
+
 func main() {
+	if os.Args[1] == convertCommand {
+		convert(os.Args[2:])
+		return
+	}
+
 	filePath := os.Args[1]
 
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Panic(filePath, separatorPanic, err)
+		log.Fatal(filePath, separatorError, err)
 	}
 
 	if err := GenerateManifests(data, os.Stdout); err != nil {
-		log.Panic(filePath, separatorPanic, err)
+		log.Fatal(filePath, separatorError, err)
+	}
+}
+
+func convert(filePaths []string) {
+	var data []byte
+	for _, filePath := range filePaths {
+		b, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Fatal(filePath, separatorError, err)
+		}
+		data = append(append(append(data, separatorYaml...), b...), '\n')
+	}
+
+	if len(filePaths) == 0 {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(convertCommand, separatorError, err)
+		}
+		data = b
+	}
+
+	if err := ConvertManifests(data, os.Stdout); err != nil {
+		log.Fatal(convertCommand, separatorError, err)
 	}
 }
 
@@ -126,13 +264,76 @@ func GenerateManifests(data []byte, out io.Writer) error {
 func makeManifests(argocdProject *ArgoCDProject) ([][]byte, error) {
 	var manifests [][]byte
 
-	b, err := makeAppProject(argocdProject)
+	environmentProfiles, err := loadEnvironmentProfiles(argocdProject)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterCatalog, err := loadClusterCatalog(argocdProject)
+	if err != nil {
+		return nil, err
+	}
+
+	if allErrs := validateArgoCDProject(argocdProject, environmentProfiles, clusterCatalog); len(allErrs) > 0 {
+		return nil, allErrs.ToAggregate()
+	}
+
+	argocdProjects, err := expandEnvironments(argocdProject)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, environmentProject := range argocdProjects {
+		resolveClusterAliases(environmentProject, clusterCatalog)
+
+		bs, err := makeProjectManifests(environmentProject, environmentProfiles)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, bs...)
+	}
+
+	if argocdProject.Spec.RBACConfigMap != nil {
+		b, err := makeRBACConfigMap(argocdProject.Spec.RBACConfigMap, argocdProjects)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, b)
+	}
+
+	return manifests, nil
+}
+
+func makeProjectManifests(argocdProject *ArgoCDProject, environmentProfiles []EnvironmentProfile) ([][]byte, error) {
+	var manifests [][]byte
+
+	environmentProfile := findEnvironmentProfile(environmentProfiles, argocdProject.Spec.Environment)
+
+	b, err := makeAppProject(argocdProject, environmentProfile)
 	if err != nil {
 		return nil, err
 	}
 	manifests = append(manifests, b)
 
-	bs, err := makeApplications(argocdProject)
+	if argocdProject.Spec.ParentApplication != nil {
+		b, err := makeParentApplication(argocdProject, environmentProfile)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, b)
+	}
+
+	if argocdProject.Spec.ApplicationSet != nil {
+		b, err := makeApplicationSet(argocdProject, environmentProfile)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, b)
+
+		return manifests, nil
+	}
+
+	bs, err := makeApplications(argocdProject, environmentProfile)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +342,7 @@ func makeManifests(argocdProject *ArgoCDProject) ([][]byte, error) {
 	return manifests, nil
 }
 
-func makeAppProject(argocdProject *ArgoCDProject) ([]byte, error) {
+func makeAppProject(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) ([]byte, error) {
 	appProject := &argocdProject.Spec.AppProject
 
 	appProject.TypeMeta = metav1.TypeMeta{
@@ -151,16 +352,12 @@ func makeAppProject(argocdProject *ArgoCDProject) ([]byte, error) {
 
 	appProject.Name = argocdProject.Name
 
-	appProject.Spec.NamespaceResourceWhitelist = []metav1.GroupKind{
-		metav1.GroupKind{
-			Group: "*",
-			Kind:  "*",
-		},
+	if err := applyResourcePolicy(argocdProject, appProject); err != nil {
+		return nil, err
 	}
 
-	// TODO only allow SourceRepos required by applications to avoid unnecessary permissions
-	appProject.Spec.SourceRepos = []string{
-		"*",
+	if appProject.Spec.SourceRepos == nil {
+		appProject.Spec.SourceRepos = makeSourceRepos(argocdProject)
 	}
 
 	if appProject.Spec.Destinations == nil {
@@ -169,6 +366,12 @@ func makeAppProject(argocdProject *ArgoCDProject) ([]byte, error) {
 			destinationMap[app.Spec.Destination.String()] = app.Spec.Destination
 		}
 
+		if argocdProject.Spec.ApplicationSet != nil {
+			if destination := argocdProject.Spec.ApplicationSet.Destination(); destination != nil {
+				destinationMap[destination.String()] = *destination
+			}
+		}
+
 		destinations := make([]argov1alpha1.ApplicationDestination, 0, len(destinationMap))
 		for _, destination := range destinationMap {
 			destinations = append(destinations, destination)
@@ -176,15 +379,105 @@ func makeAppProject(argocdProject *ArgoCDProject) ([]byte, error) {
 		appProject.Spec.Destinations = destinations
 	}
 
+	if namespace := argocdProject.Spec.ApplicationNamespace; namespace != "" && !slices.Contains(appProject.Spec.SourceNamespaces, namespace) {
+		appProject.Spec.SourceNamespaces = append(appProject.Spec.SourceNamespaces, namespace)
+	}
+
 	readOnlyProjectRole := makeProjectRole(ReadOnly, argocdProject, appProject)
 	appProject.Spec.Roles = append(appProject.Spec.Roles, *readOnlyProjectRole)
 
 	readSyncProjectRole := makeProjectRole(ReadSync, argocdProject, appProject)
 	appProject.Spec.Roles = append(appProject.Spec.Roles, *readSyncProjectRole)
 
+	if argocdProject.Spec.CIRole != nil {
+		ciSyncProjectRole := makeProjectRole(CISync, argocdProject, appProject)
+		ciSyncProjectRole.JWTTokens = argocdProject.Spec.CIRole.JWTTokens
+		appProject.Spec.Roles = append(appProject.Spec.Roles, *ciSyncProjectRole)
+	}
+
+	accessProjectRoles := makeAccessProjectRoles(argocdProject, appProject)
+	appProject.Spec.Roles = append(appProject.Spec.Roles, accessProjectRoles...)
+
+	appProject.Spec.Roles = mergeProjectRoles(appProject.Spec.Roles)
+
+	grantEnvironmentProfile(environmentProfile, appProject, argocdProject.Spec.ApplicationNamespace)
+
+	syncWindows := makeSyncWindows(argocdProject, environmentProfile)
+	appProject.Spec.SyncWindows = append(appProject.Spec.SyncWindows, syncWindows...)
+
+	appProject.Spec.SignatureKeys = makeSignatureKeys(argocdProject, appProject)
+	appProject.Spec.OrphanedResources = makeOrphanedResources(argocdProject, environmentProfile, appProject)
+
+	canonicalizeAppProject(appProject)
+
 	return marshalYAMLWithoutStatusField(appProject)
 }
 
+func canonicalizeAppProject(appProject *argov1alpha1.AppProject) {
+	destinations := appProject.Spec.Destinations
+	sort.Slice(destinations, func(i, j int) bool {
+		destinationI := destinations[i]
+		stringI := fmt.Sprintf("%s\x00%s\x00%s", destinationI.Server, destinationI.Name, destinationI.Namespace)
+
+		destinationJ := destinations[j]
+		stringJ := fmt.Sprintf("%s\x00%s\x00%s", destinationJ.Server, destinationJ.Name, destinationJ.Namespace)
+
+		return stringI < stringJ
+	})
+
+	roles := appProject.Spec.Roles
+	for _, role := range roles {
+		groups := role.Groups
+		sort.Slice(groups, func(i, j int) bool {
+			return groups[i] < groups[j]
+		})
+
+		policies := role.Policies
+		sort.Slice(policies, func(i, j int) bool {
+			return policies[i] < policies[j]
+		})
+	}
+
+	sort.SliceStable(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+}
+
+func makeSourceRepos(argocdProject *ArgoCDProject) []string {
+	if argocdProject.Spec.AllowAnySourceRepo {
+		return []string{
+			anySourceRepo,
+		}
+	}
+
+	repoMap := make(map[string]struct{})
+	for _, app := range argocdProject.Spec.ApplicationTemplates {
+		for _, source := range app.Spec.GetSources() {
+			if source.RepoURL != "" {
+				repoMap[source.RepoURL] = struct{}{}
+			}
+		}
+	}
+
+	if argocdProject.Spec.ApplicationSet != nil {
+		for _, repoURL := range argocdProject.Spec.ApplicationSet.RepoURLs() {
+			repoMap[repoURL] = struct{}{}
+		}
+	}
+
+	if argocdProject.Spec.ParentApplication != nil && argocdProject.Spec.ParentApplication.Source.RepoURL != "" {
+		repoMap[argocdProject.Spec.ParentApplication.Source.RepoURL] = struct{}{}
+	}
+
+	repos := make([]string, 0, len(repoMap))
+	for repo := range repoMap {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	return repos
+}
+
 func makeProjectRole(accessLevel accessLevel, argocdProject *ArgoCDProject, appProject *argov1alpha1.AppProject) *argov1alpha1.ProjectRole {
 	var groups []string
 	switch accessLevel {
@@ -196,14 +489,115 @@ func makeProjectRole(accessLevel accessLevel, argocdProject *ArgoCDProject, appP
 
 	return &argov1alpha1.ProjectRole{
 		Name:     accessLevel.String(),
-		Policies: accessLevel.Policies(appProject.Name, argocdProject.Spec.Environment),
+		Policies: accessLevel.Policies(appProject.Name, argocdProject.Spec.ApplicationNamespace),
 		Groups:   groups,
 	}
 }
 
-func makeApplications(argocdProject *ArgoCDProject) ([][]byte, error) {
+func makeAccessProjectRoles(argocdProject *ArgoCDProject, appProject *argov1alpha1.AppProject) []argov1alpha1.ProjectRole {
+	projectRoles := make([]argov1alpha1.ProjectRole, 0, len(argocdProject.Spec.AccessRoles))
+	for i := range argocdProject.Spec.AccessRoles {
+		accessRole := &argocdProject.Spec.AccessRoles[i]
+
+		projectRoles = append(projectRoles, argov1alpha1.ProjectRole{
+			Name:     accessRole.Name,
+			Policies: accessRole.Policies(appProject.Name, argocdProject.Spec.ApplicationNamespace),
+			Groups:   accessRole.Groups,
+		})
+	}
+
+	return projectRoles
+}
+
+// mergeProjectRoles combines roles sharing the same name, which validation only allows between appProjectTemplate
+// and generated roles when spec.mergeTemplateRoles is set.
+func mergeProjectRoles(roles []argov1alpha1.ProjectRole) []argov1alpha1.ProjectRole {
+	mergedRoles := make([]argov1alpha1.ProjectRole, 0, len(roles))
+	roleIndexes := make(map[string]int, len(roles))
+
+	for _, role := range roles {
+		i, ok := roleIndexes[role.Name]
+		if !ok {
+			roleIndexes[role.Name] = len(mergedRoles)
+			mergedRoles = append(mergedRoles, role)
+			continue
+		}
+
+		mergedRole := &mergedRoles[i]
+		if mergedRole.Description == "" {
+			mergedRole.Description = role.Description
+		}
+		mergedRole.Groups = mergeStrings(mergedRole.Groups, role.Groups)
+		mergedRole.Policies = mergeStrings(mergedRole.Policies, role.Policies)
+		mergedRole.JWTTokens = append(mergedRole.JWTTokens, role.JWTTokens...)
+	}
+
+	return mergedRoles
+}
+
+func mergeStrings(values []string, extraValues []string) []string {
+	for _, value := range extraValues {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func generatedRoleNames(argocdProject *ArgoCDProject) map[string]bool {
+	roleNames := map[string]bool{
+		ReadOnly.String(): true,
+		ReadSync.String(): true,
+	}
+	if argocdProject.Spec.CIRole != nil {
+		roleNames[CISync.String()] = true
+	}
+
+	for _, accessRole := range argocdProject.Spec.AccessRoles {
+		roleNames[accessRole.Name] = true
+	}
+
+	return roleNames
+}
+
+func makeApplications(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) ([][]byte, error) {
 	apps := argocdProject.Spec.ApplicationTemplates
+	if err := prepareApplications(argocdProject, environmentProfile, apps); err != nil {
+		return nil, err
+	}
+
 	manifests := make([][]byte, 0, len(apps))
+	for i := range apps {
+		b, err := marshalYAMLWithoutStatusField(&apps[i])
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, b)
+	}
+
+	return manifests, nil
+}
+
+func prepareApplications(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile, apps []argov1alpha1.Application) error {
+	conventions := argocdProject.Spec.Conventions
+	applicationDefaults := makeApplicationDefaults(argocdProject, environmentProfile)
+	notificationAnnotations := makeNotificationAnnotations(argocdProject, environmentProfile)
+
+	pathTemplate, err := parseConvention("path", conventions.Path, defaultPathConvention)
+	if err != nil {
+		return err
+	}
+
+	targetRevisionTemplate, err := parseConvention("targetRevision", conventions.TargetRevision, defaultTargetRevisionConvention)
+	if err != nil {
+		return err
+	}
+
+	syncWaves, err := makeSyncWaves(apps)
+	if err != nil {
+		return err
+	}
 
 	for i := range apps {
 		app := &apps[i]
@@ -213,23 +607,116 @@ func makeApplications(argocdProject *ArgoCDProject) ([][]byte, error) {
 			Kind:       application.ApplicationKind,
 		}
 
+		app.Namespace = argocdProject.Spec.ApplicationNamespace
 		app.Spec.Project = argocdProject.Name
 
+		applicationDefaults.apply(&app.Finalizers, &app.Spec)
+		if argocdProject.Spec.NamespaceMetadata != nil {
+			argocdProject.Spec.NamespaceMetadata.apply(&app.Spec)
+		}
+		applySyncWave(app, syncWaves)
+		applyNotificationAnnotations(&app.Annotations, notificationAnnotations)
+
+		sources, err := environmentSources(app)
+		if err != nil {
+			return err
+		}
+
 		if argocdProject.Spec.Environment != "" {
-			if app.Spec.Source.Path == "" {
-				app.Spec.Source.Path = fmt.Sprintf("./k8s/overlays/%s", argocdProject.Spec.Environment)
+			data := conventionData{
+				AppName:     app.Name,
+				ProjectName: argocdProject.baseName(),
+				Environment: argocdProject.Spec.Environment,
+			}
+
+			path, err := executeConvention(pathTemplate, data)
+			if err != nil {
+				return err
+			}
+
+			targetRevision, err := executeConvention(targetRevisionTemplate, data)
+			if err != nil {
+				return err
+			}
+
+			for _, source := range sources {
+				if source.Path == "" {
+					source.Path = path
+				}
+
+				if source.TargetRevision == "" || !conventions.PreserveTargetRevision {
+					source.TargetRevision = targetRevision
+				}
 			}
-			app.Spec.Source.TargetRevision = fmt.Sprintf("env-%s", argocdProject.Spec.Environment)
 		}
 
-		b, err := marshalYAMLWithoutStatusField(app)
-		if err != nil {
-			return nil, err
+		app.Name += argocdProject.environmentSuffix
+	}
+
+	return nil
+}
+
+func parseConvention(name string, text string, defaultText string) (*template.Template, error) {
+	if text == "" {
+		text = defaultText
+	}
+
+	return template.New(name).Option(conventionOption).Parse(text)
+}
+
+func executeConvention(tmpl *template.Template, data conventionData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+func environmentSources(app *argov1alpha1.Application) ([]*argov1alpha1.ApplicationSource, error) {
+	environmentSource, marked := popAnnotation(&app.ObjectMeta, environmentSourceAnnotation)
+
+	if !app.Spec.HasMultipleSources() {
+		if app.Spec.Source == nil || app.Spec.Source.IsHelm() {
+			return nil, nil
 		}
-		manifests = append(manifests, b)
+
+		return []*argov1alpha1.ApplicationSource{
+			app.Spec.Source,
+		}, nil
 	}
 
-	return manifests, nil
+	var sources []*argov1alpha1.ApplicationSource
+	for i := range app.Spec.Sources {
+		source := &app.Spec.Sources[i]
+
+		switch {
+		case marked && (environmentSource == source.Ref || environmentSource == strconv.Itoa(i)):
+			sources = append(sources, source)
+		case !marked && !source.IsHelm():
+			sources = append(sources, source)
+		}
+	}
+
+	if marked && len(sources) == 0 {
+		return nil, fmt.Errorf("application %s has no source matching %s", app.Name, environmentSource)
+	}
+
+	return sources, nil
+}
+
+func popAnnotation(objectMeta *metav1.ObjectMeta, key string) (string, bool) {
+	value, ok := objectMeta.Annotations[key]
+	if !ok {
+		return "", false
+	}
+
+	delete(objectMeta.Annotations, key)
+	if len(objectMeta.Annotations) == 0 {
+		objectMeta.Annotations = nil
+	}
+
+	return value, true
 }
 
 func marshalYAMLWithoutStatusField(v interface{}) ([]byte, error) {
@@ -3,12 +3,17 @@ package main_test
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 
 	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/onsi/ginkgo/v2"
 	g "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
@@ -139,9 +144,2028 @@ var _ = ginkgo.Describe("ArgoCDProject", func() {
 				},
 			},
 		}),
+		ginkgo.Entry("with any source repo allowed", newArgoCDProject("github-checker", main.ProjectSpec{
+			AccessControl: main.AppProjectAccessControl{
+				ReadSync: []string{
+					"sre:eng-0",
+				},
+			},
+			AllowAnySourceRepo: true,
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})),
+		ginkgo.Entry("with access roles", newArgoCDProject("github-checker", main.ProjectSpec{
+			AccessControl: main.AppProjectAccessControl{
+				ReadOnly: []string{
+					"sre:eng-1",
+				},
+			},
+			AccessRoles: []main.AccessRole{
+				main.AccessRole{
+					Name: "deployer",
+					Groups: []string{
+						"qa:eng-0",
+					},
+					Inherits: []string{
+						main.ReadOnly.String(),
+					},
+					Permissions: []main.AccessPermission{
+						main.AccessPermission{
+							Actions: []string{
+								"sync",
+								"action/apps/Deployment/restart",
+							},
+						},
+					},
+				},
+				main.AccessRole{
+					Name: "auditor",
+					Groups: []string{
+						"security:eng-0",
+					},
+					Permissions: []main.AccessPermission{
+						main.AccessPermission{
+							Resource: "logs",
+							Actions: []string{
+								"get",
+							},
+						},
+					},
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})),
+		ginkgo.Entry("with CI role", newArgoCDProject("github-checker", main.ProjectSpec{
+			AccessControl: main.AppProjectAccessControl{
+				ReadSync: []string{
+					"sre:eng-0",
+				},
+			},
+			CIRole: &main.CIRole{
+				JWTTokens: []argov1alpha1.JWTToken{
+					argov1alpha1.JWTToken{
+						ID:       "github-actions",
+						IssuedAt: 1700000000,
+					},
+				},
+			},
+			Environment: "staging",
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})),
+		ginkgo.Entry("with application namespace", newArgoCDProject("github-checker", main.ProjectSpec{
+			AccessControl: main.AppProjectAccessControl{
+				ReadSync: []string{
+					"sre:eng-0",
+				},
+			},
+			ApplicationNamespace: "sre-apps",
+			Environment:          "staging",
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})),
+		ginkgo.Entry("with resource lists", newArgoCDProject("github-checker", main.ProjectSpec{
+			ResourcePresets: []string{
+				"no-rbac",
+			},
+			AppProject: argov1alpha1.AppProject{
+				Spec: argov1alpha1.AppProjectSpec{
+					NamespaceResourceWhitelist: []metav1.GroupKind{
+						metav1.GroupKind{
+							Group: "apps",
+							Kind:  "*",
+						},
+					},
+					NamespaceResourceBlacklist: []metav1.GroupKind{
+						metav1.GroupKind{
+							Group: "",
+							Kind:  "Secret",
+						},
+					},
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})),
+	)
+})
+
+var _ = ginkgo.Describe("ArgoCDProject environment profiles", func() {
+	applicationTemplates := []argov1alpha1.Application{
+		newApplicationTemplate("github-checker-app", "github-checker"),
+	}
+
+	ginkgo.It("grants override on staging by default", func() {
+		appProject := generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment:          "staging",
+			ApplicationTemplates: applicationTemplates,
+		}))
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name": g.Equal(main.ReadSync.String()),
+			"Policies": g.ContainElement(
+				"p, proj:github-checker:read-sync, applications, override, github-checker/*, allow",
+			),
+		})))
+	})
+
+	ginkgo.It("grants nothing on unknown environments", func() {
+		appProject := generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment:          "production",
+			ApplicationTemplates: applicationTemplates,
+		}))
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name":     g.Equal(main.ReadSync.String()),
+			"Policies": g.ConsistOf(main.ReadSync.Policies("github-checker", "")),
+		})))
+	})
+
+	ginkgo.It("grants actions declared in spec and profiles file", func() {
+		profilesFile := filepath.Join(ginkgo.GinkgoT().TempDir(), "environmentProfiles.yaml")
+		g.Expect(os.WriteFile(profilesFile, []byte(`
+environmentProfiles:
+  - name: sandbox
+    actions:
+      - override
+      - delete
+`), 0644)).To(g.Succeed())
+
+		appProject := generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment:             "qa",
+			EnvironmentProfilesFile: profilesFile,
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name: "qa",
+					Actions: []string{
+						"exec",
+						"action/*",
+					},
+				},
+			},
+			ApplicationTemplates: applicationTemplates,
+		}))
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name": g.Equal(main.ReadSync.String()),
+			"Policies": g.And(
+				g.ContainElements(
+					"p, proj:github-checker:read-sync, exec, create, github-checker/*, allow",
+					"p, proj:github-checker:read-sync, applications, action/*, github-checker/*, allow",
+				),
+				g.Not(g.ContainElement(
+					"p, proj:github-checker:read-sync, applications, delete, github-checker/*, allow",
+				)),
+			),
+		})))
+
+		appProject = generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment:             "sandbox",
+			EnvironmentProfilesFile: profilesFile,
+			ApplicationTemplates:    applicationTemplates,
+		}))
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name": g.Equal(main.ReadSync.String()),
+			"Policies": g.ContainElements(
+				"p, proj:github-checker:read-sync, applications, override, github-checker/*, allow",
+				"p, proj:github-checker:read-sync, applications, delete, github-checker/*, allow",
+			),
+		})))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject multi-source applications", func() {
+	newMultiSourceApplication := func(annotations map[string]string) argov1alpha1.Application {
+		app := newApplicationTemplate("github-checker-app", "github-checker")
+		app.Annotations = annotations
+		app.Spec.Source = nil
+		app.Spec.Sources = argov1alpha1.ApplicationSources{
+			argov1alpha1.ApplicationSource{
+				RepoURL:        "https://charts.example.com",
+				Chart:          "github-checker",
+				TargetRevision: "1.2.3",
+			},
+			argov1alpha1.ApplicationSource{
+				RepoURL: "https://github.com/inloco/github-checker.git",
+				Ref:     "values",
+			},
+		}
+
+		return app
+	}
+
+	ginkgo.It("defaults every source but charts", func() {
+		apps := generateApplications(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "staging",
+			ApplicationTemplates: []argov1alpha1.Application{
+				newMultiSourceApplication(nil),
+			},
+		}))
+
+		g.Expect(apps).To(g.HaveLen(1))
+		g.Expect(apps[0].Spec.Source).To(g.BeNil())
+		g.Expect(apps[0].Spec.Sources).To(g.Equal(argov1alpha1.ApplicationSources{
+			argov1alpha1.ApplicationSource{
+				RepoURL:        "https://charts.example.com",
+				Chart:          "github-checker",
+				TargetRevision: "1.2.3",
+			},
+			argov1alpha1.ApplicationSource{
+				RepoURL:        "https://github.com/inloco/github-checker.git",
+				Path:           "./k8s/overlays/staging",
+				TargetRevision: "env-staging",
+				Ref:            "values",
+			},
+		}))
+	})
+
+	ginkgo.It("defaults only the marked source", func() {
+		apps := generateApplications(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "staging",
+			ApplicationTemplates: []argov1alpha1.Application{
+				newMultiSourceApplication(map[string]string{
+					"argocdproject.incognia.com/environment-source": "values",
+				}),
+			},
+		}))
+
+		g.Expect(apps).To(g.HaveLen(1))
+		g.Expect(apps[0].Annotations).To(g.BeEmpty())
+		g.Expect(apps[0].Spec.Sources).To(g.Equal(argov1alpha1.ApplicationSources{
+			argov1alpha1.ApplicationSource{
+				RepoURL:        "https://charts.example.com",
+				Chart:          "github-checker",
+				TargetRevision: "1.2.3",
+			},
+			argov1alpha1.ApplicationSource{
+				RepoURL:        "https://github.com/inloco/github-checker.git",
+				Path:           "./k8s/overlays/staging",
+				TargetRevision: "env-staging",
+				Ref:            "values",
+			},
+		}))
+	})
+
+	ginkgo.It("derives source repos from every source", func() {
+		appProject := generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			ApplicationTemplates: []argov1alpha1.Application{
+				newMultiSourceApplication(nil),
+			},
+		}))
+
+		g.Expect(appProject.Spec.SourceRepos).To(g.Equal([]string{
+			"https://charts.example.com",
+			"https://github.com/inloco/github-checker.git",
+		}))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject conventions", func() {
+	anotherCheckerApp := newApplicationTemplate("another-checker-app", "another-checker")
+	anotherCheckerApp.Spec.Source.Path = "k8s"
+	anotherCheckerApp.Spec.Source.TargetRevision = "HEAD"
+
+	applicationTemplates := []argov1alpha1.Application{
+		newApplicationTemplate("github-checker-app", "github-checker"),
+		anotherCheckerApp,
+	}
+
+	ginkgo.It("renders path and targetRevision templates", func() {
+		apps := generateApplications(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "qa",
+			Conventions: main.SourceConventions{
+				Path:                   "deploy/{{ .Environment }}/{{ .AppName }}",
+				TargetRevision:         "release/{{ .ProjectName }}-{{ .Environment }}",
+				PreserveTargetRevision: true,
+			},
+			ApplicationTemplates: applicationTemplates,
+		}))
+
+		g.Expect(apps).To(gstruct.MatchAllElements(func(e interface{}) string {
+			return e.(argov1alpha1.Application).Name
+		}, gstruct.Elements{
+			"github-checker-app": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Source": gstruct.PointTo(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+						"Path":           g.Equal("deploy/qa/github-checker-app"),
+						"TargetRevision": g.Equal("release/github-checker-qa"),
+					})),
+				}),
+			}),
+			"another-checker-app": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Source": gstruct.PointTo(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+						"Path":           g.Equal("k8s"),
+						"TargetRevision": g.Equal("HEAD"),
+					})),
+				}),
+			}),
+		}))
+	})
+
+	ginkgo.It("fails on unknown template fields", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "qa",
+			Conventions: main.SourceConventions{
+				Path: "deploy/{{ .Cluster }}",
+			},
+			ApplicationTemplates: applicationTemplates,
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, io.Discard)).NotTo(g.Succeed())
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject application sets", func() {
+	ginkgo.It("emits a list generator instead of applications", func() {
+		argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "staging",
+			ApplicationSet: &main.ApplicationSetOutput{
+				Template: argov1alpha1.ApplicationSetTemplate{
+					Spec: argov1alpha1.ApplicationSpec{
+						SyncPolicy: &argov1alpha1.SyncPolicy{
+							Automated: &argov1alpha1.SyncPolicyAutomated{},
+						},
+					},
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+				newApplicationTemplate("another-checker-app", "another-checker"),
+			},
+		})
+
+		g.Expect(generateApplications(argoCDProject)).To(g.BeEmpty())
+
+		appProject := generateAppProject(argoCDProject)
+		g.Expect(appProject.Spec.SourceRepos).To(g.HaveLen(2))
+
+		applicationSets := generateApplicationSets(argoCDProject)
+		g.Expect(applicationSets).To(g.HaveLen(1))
+
+		applicationSet := applicationSets[0]
+		g.Expect(applicationSet.Name).To(g.Equal("github-checker"))
+		g.Expect(applicationSet.Spec.Template.Spec.Project).To(g.Equal("github-checker"))
+		g.Expect(applicationSet.Spec.Template.Spec.SyncPolicy).NotTo(g.BeNil())
+		g.Expect(applicationSet.Spec.Generators).To(g.HaveLen(1))
+		g.Expect(applicationSet.Spec.Generators[0].List).NotTo(g.BeNil())
+
+		var elements []map[string]string
+		for _, element := range applicationSet.Spec.Generators[0].List.Elements {
+			var values map[string]string
+			g.Expect(yaml.Unmarshal(element.Raw, &values)).To(g.Succeed())
+			elements = append(elements, values)
+		}
+		g.Expect(elements).To(g.ConsistOf(
+			g.SatisfyAll(
+				g.HaveKeyWithValue("name", "github-checker-app"),
+				g.HaveKeyWithValue("path", "./k8s/overlays/staging"),
+				g.HaveKeyWithValue("targetRevision", "env-staging"),
+				g.HaveKeyWithValue("destinationNamespace", "github-checker"),
+			),
+			g.SatisfyAll(
+				g.HaveKeyWithValue("name", "another-checker-app"),
+				g.HaveKeyWithValue("path", "./k8s/overlays/staging"),
+				g.HaveKeyWithValue("targetRevision", "env-staging"),
+				g.HaveKeyWithValue("destinationNamespace", "another-checker"),
+			),
+		))
+	})
+
+	ginkgo.It("rejects template fields the list generator can not carry", func() {
+		app := newApplicationTemplate("github-checker-app", "github-checker")
+		app.Labels = map[string]string{
+			"team": "sre",
+		}
+		app.Annotations = map[string]string{
+			"notifications.argoproj.io/subscribe.on-deployed.email": "sre@incognia.com",
+		}
+		app.Spec.Source.Helm = &argov1alpha1.ApplicationSourceHelm{
+			ValueFiles: []string{"values-staging.yaml"},
+		}
+		app.Spec.SyncPolicy = &argov1alpha1.SyncPolicy{
+			Automated: &argov1alpha1.SyncPolicyAutomated{
+				Prune: true,
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			ApplicationSet: &main.ApplicationSetOutput{},
+			ApplicationTemplates: []argov1alpha1.Application{
+				app,
+			},
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.HaveOccurred())
+		g.Expect(err.Error()).To(g.SatisfyAll(
+			g.ContainSubstring("spec.applicationTemplates[0].metadata.labels: Forbidden"),
+			g.ContainSubstring("spec.applicationTemplates[0].metadata.annotations[notifications.argoproj.io/subscribe.on-deployed.email]: Forbidden"),
+			g.ContainSubstring("spec.applicationTemplates[0].spec.source.helm: Forbidden"),
+			g.ContainSubstring("spec.applicationTemplates[0].spec.syncPolicy: Forbidden"),
+		))
+	})
+
+	gitArgoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		Environment: "staging",
+		AppProject: argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				Destinations: []argov1alpha1.ApplicationDestination{
+					argov1alpha1.ApplicationDestination{
+						Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+						Namespace: "*",
+					},
+				},
+			},
+		},
+		ApplicationSet: &main.ApplicationSetOutput{
+			Name:      "monorepo",
+			Generator: "git",
+			Git: &argov1alpha1.GitGenerator{
+				Directories: []argov1alpha1.GitDirectoryGeneratorItem{
+					argov1alpha1.GitDirectoryGeneratorItem{
+						Path: "apps/*",
+					},
+				},
+			},
+			Template: argov1alpha1.ApplicationSetTemplate{
+				ApplicationSetTemplateMeta: argov1alpha1.ApplicationSetTemplateMeta{
+					Name: "{{ .path.basename }}",
+				},
+				Spec: argov1alpha1.ApplicationSpec{
+					Source: &argov1alpha1.ApplicationSource{
+						RepoURL: "https://github.com/inloco/monorepo.git",
+						Path:    "{{ .path.path }}",
+					},
+					Destination: argov1alpha1.ApplicationDestination{
+						Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+						Namespace: "{{ .path.basename }}",
+					},
+				},
+			},
+		},
+	})
+
+	ginkgo.It("defaults the git generator revision", func() {
+		applicationSets := generateApplicationSets(gitArgoCDProject)
+
+		g.Expect(applicationSets).To(g.HaveLen(1))
+		g.Expect(applicationSets[0].Name).To(g.Equal("monorepo"))
+		g.Expect(applicationSets[0].Spec.Generators[0].Git).To(gstruct.PointTo(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"RepoURL":  g.Equal("https://github.com/inloco/monorepo.git"),
+			"Revision": g.Equal("env-staging"),
+		})))
+		g.Expect(applicationSets[0].Spec.Template.Spec.Source).To(gstruct.PointTo(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Path":           g.Equal("{{ .path.path }}"),
+			"TargetRevision": g.Equal("env-staging"),
+		})))
+	})
+
+	ginkgo.It("allows the git generator destination in the project", func() {
+		argoCDProject := gitArgoCDProject
+		argoCDProject.Spec.AppProject = argov1alpha1.AppProject{}
+
+		applicationSet := *argoCDProject.Spec.ApplicationSet
+		applicationSet.Template.Spec.Destination.Namespace = "monorepo"
+		argoCDProject.Spec.ApplicationSet = &applicationSet
+
+		g.Expect(generateAppProject(argoCDProject).Spec.Destinations).To(g.Equal([]argov1alpha1.ApplicationDestination{
+			argov1alpha1.ApplicationDestination{
+				Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+				Namespace: "monorepo",
+			},
+		}))
+	})
+
+	ginkgo.It("requires project destinations for templated git generator destinations", func() {
+		argoCDProject := gitArgoCDProject
+		argoCDProject.Spec.AppProject = argov1alpha1.AppProject{}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.applicationSet.template.spec.destination: Invalid value")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject canonical output", func() {
+	anotherCheckerApp := newApplicationTemplate("another-checker-app", "another-checker")
+	anotherCheckerApp.Spec.Destination.Name = "arn:aws:eks:us:123456789876:cluster/Global-Product"
+
+	foregroundCheckerApp := newApplicationTemplate("foreground-checker-app", "foreground-checker")
+	foregroundCheckerApp.Spec.Destination = argov1alpha1.ApplicationDestination{
+		Server:    "https://kubernetes.default.svc",
+		Namespace: "foreground-checker",
+	}
+
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AccessControl: main.AppProjectAccessControl{
+			ReadSync: []string{
+				"sre:eng-1",
+				"sre:eng-0",
+			},
+		},
+		ApplicationTemplates: []argov1alpha1.Application{
+			newApplicationTemplate("github-checker-app", "github-checker"),
+			anotherCheckerApp,
+			foregroundCheckerApp,
+		},
+	})
+
+	ginkgo.It("sorts destinations, roles, groups and policies", func() {
+		appProject := generateAppProject(argoCDProject)
+
+		g.Expect(appProject.Spec.Destinations).To(g.Equal([]argov1alpha1.ApplicationDestination{
+			argov1alpha1.ApplicationDestination{
+				Name:      "arn:aws:eks:us:123456789876:cluster/Global-Product",
+				Namespace: "another-checker",
+			},
+			argov1alpha1.ApplicationDestination{
+				Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+				Namespace: "github-checker",
+			},
+			argov1alpha1.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: "foreground-checker",
+			},
+		}))
+
+		var roleNames []string
+		for _, role := range appProject.Spec.Roles {
+			roleNames = append(roleNames, role.Name)
+			g.Expect(sort.StringsAreSorted(role.Groups)).To(g.BeTrue())
+			g.Expect(sort.StringsAreSorted(role.Policies)).To(g.BeTrue())
+		}
+		g.Expect(sort.StringsAreSorted(roleNames)).To(g.BeTrue())
+	})
+
+	ginkgo.It("is reproducible", func() {
+		expected := generateManifests(argoCDProject)
+		for i := 0; i < 10; i++ {
+			g.Expect(generateManifests(argoCDProject)).To(g.Equal(expected))
+		}
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject sync windows", func() {
+	applicationTemplates := []argov1alpha1.Application{
+		newApplicationTemplate("github-checker-app", "github-checker"),
+	}
+
+	ginkgo.It("translates spec and environment sync windows", func() {
+		appProject := generateAppProject(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "production",
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name: "production",
+					SyncWindows: []main.SyncWindow{
+						main.SyncWindow{
+							Kind:     "deny",
+							Schedule: "0 0 * * 6",
+							Duration: "48h",
+							TimeZone: "America/Sao_Paulo",
+						},
+					},
+				},
+			},
+			SyncWindows: []main.SyncWindow{
+				main.SyncWindow{
+					Kind:     "allow",
+					Schedule: "0 9 * * 1-5",
+					Duration: "8h",
+					Applications: []string{
+						"github-checker-app",
+					},
+					ManualSync: true,
+				},
+			},
+			ApplicationTemplates: applicationTemplates,
+		}))
+
+		g.Expect(appProject.Spec.SyncWindows).To(g.Equal(argov1alpha1.SyncWindows{
+			&argov1alpha1.SyncWindow{
+				Kind:     "deny",
+				Schedule: "0 0 * * 6",
+				Duration: "48h",
+				Applications: []string{
+					"*",
+				},
+				TimeZone: "America/Sao_Paulo",
+			},
+			&argov1alpha1.SyncWindow{
+				Kind:     "allow",
+				Schedule: "0 9 * * 1-5",
+				Duration: "8h",
+				Applications: []string{
+					"github-checker-app",
+				},
+				ManualSync: true,
+			},
+		}))
+	})
+
+	ginkgo.DescribeTable("rejects invalid sync windows", func(syncWindow main.SyncWindow) {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			SyncWindows: []main.SyncWindow{
+				syncWindow,
+			},
+			ApplicationTemplates: applicationTemplates,
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, io.Discard)).NotTo(g.Succeed())
+	},
+		ginkgo.Entry("with unknown kind", main.SyncWindow{
+			Kind:     "block",
+			Schedule: "0 0 * * *",
+			Duration: "1h",
+		}),
+		ginkgo.Entry("with invalid schedule", main.SyncWindow{
+			Kind:     "deny",
+			Schedule: "0 0 * *",
+			Duration: "1h",
+		}),
+		ginkgo.Entry("with invalid duration", main.SyncWindow{
+			Kind:     "deny",
+			Schedule: "0 0 * * *",
+			Duration: "1 hour",
+		}),
+		ginkgo.Entry("with invalid time zone", main.SyncWindow{
+			Kind:     "deny",
+			Schedule: "0 0 * * *",
+			Duration: "1h",
+			TimeZone: "Mars/Olympus_Mons",
+		}),
+		ginkgo.Entry("with unknown application", main.SyncWindow{
+			Kind:     "deny",
+			Schedule: "0 0 * * *",
+			Duration: "1h",
+			Applications: []string{
+				"unknown-app",
+			},
+		}),
+	)
+})
+
+var _ = ginkgo.Describe("ArgoCDProject resource presets", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AppProject: argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				ClusterResourceBlacklist: []metav1.GroupKind{
+					metav1.GroupKind{
+						Group: "rbac.authorization.k8s.io",
+						Kind:  "ClusterRole",
+					},
+				},
+			},
+		},
+	})
+
+	ginkgo.It("merges presets into blacklists", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ResourcePresets = []string{"no-rbac", "no-crds"}
+
+		appProject := generateAppProject(argoCDProject)
+
+		g.Expect(appProject.Spec.NamespaceResourceBlacklist).To(g.Equal([]metav1.GroupKind{
+			metav1.GroupKind{
+				Group: "rbac.authorization.k8s.io",
+				Kind:  "Role",
+			},
+			metav1.GroupKind{
+				Group: "rbac.authorization.k8s.io",
+				Kind:  "RoleBinding",
+			},
+		}))
+		g.Expect(appProject.Spec.ClusterResourceBlacklist).To(g.Equal([]metav1.GroupKind{
+			metav1.GroupKind{
+				Group: "rbac.authorization.k8s.io",
+				Kind:  "ClusterRole",
+			},
+			metav1.GroupKind{
+				Group: "rbac.authorization.k8s.io",
+				Kind:  "ClusterRoleBinding",
+			},
+			metav1.GroupKind{
+				Group: "apiextensions.k8s.io",
+				Kind:  "CustomResourceDefinition",
+			},
+		}))
+	})
+
+	ginkgo.It("rejects unknown presets", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ResourcePresets = []string{"no-secrets"}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, io.Discard)).NotTo(g.Succeed())
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject application defaults", func() {
+	resourcesFinalizer := true
+
+	anotherCheckerApp := newApplicationTemplate("another-checker-app", "another-checker")
+	anotherCheckerApp.Finalizers = []string{
+		"resources-finalizer.argocd.argoproj.io/background",
+	}
+	anotherCheckerApp.Spec.SyncPolicy = &argov1alpha1.SyncPolicy{}
+
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		EnvironmentProfiles: []main.EnvironmentProfile{
+			main.EnvironmentProfile{
+				Name: "production",
+				ApplicationDefaults: main.ApplicationDefaults{
+					SyncPolicy: &argov1alpha1.SyncPolicy{
+						Automated: &argov1alpha1.SyncPolicyAutomated{
+							SelfHeal: true,
+						},
+					},
+				},
+			},
+		},
+		ApplicationDefaults: main.ApplicationDefaults{
+			SyncPolicy: &argov1alpha1.SyncPolicy{
+				Automated: &argov1alpha1.SyncPolicyAutomated{
+					Prune:    true,
+					SelfHeal: true,
+				},
+				SyncOptions: argov1alpha1.SyncOptions{
+					"CreateNamespace=true",
+					"ServerSideApply=true",
+				},
+			},
+			ResourcesFinalizer: &resourcesFinalizer,
+		},
+		ApplicationTemplates: []argov1alpha1.Application{
+			newApplicationTemplate("github-checker-app", "github-checker"),
+			anotherCheckerApp,
+		},
+	})
+
+	ginkgo.It("applies project defaults unless the template overrides them", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environment = "staging"
+
+		apps := generateApplications(argoCDProject)
+
+		g.Expect(apps).To(gstruct.MatchAllElements(func(e interface{}) string {
+			return e.(argov1alpha1.Application).Name
+		}, gstruct.Elements{
+			"github-checker-app": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"ObjectMeta": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Finalizers": g.Equal([]string{argov1alpha1.ResourcesFinalizerName}),
+				}),
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"SyncPolicy": g.Equal(&argov1alpha1.SyncPolicy{
+						Automated: &argov1alpha1.SyncPolicyAutomated{
+							Prune:    true,
+							SelfHeal: true,
+						},
+						SyncOptions: argov1alpha1.SyncOptions{
+							"CreateNamespace=true",
+							"ServerSideApply=true",
+						},
+					}),
+				}),
+			}),
+			"another-checker-app": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"ObjectMeta": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Finalizers": g.Equal([]string{"resources-finalizer.argocd.argoproj.io/background"}),
+				}),
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"SyncPolicy": g.Equal(&argov1alpha1.SyncPolicy{}),
+				}),
+			}),
+		}))
+	})
+
+	ginkgo.It("prefers environment profile defaults", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environment = "production"
+
+		apps := generateApplications(argoCDProject)
+
+		g.Expect(apps[0].Finalizers).To(g.Equal([]string{argov1alpha1.ResourcesFinalizerName}))
+		g.Expect(apps[0].Spec.SyncPolicy).To(g.Equal(&argov1alpha1.SyncPolicy{
+			Automated: &argov1alpha1.SyncPolicyAutomated{
+				SelfHeal: true,
+			},
+		}))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject RBAC ConfigMap", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AccessControl: main.AppProjectAccessControl{
+			ReadOnly: []string{
+				"inloco:everyone",
+			},
+			ReadSync: []string{
+				"inloco:sre",
+				"inloco:github-checker-devs",
+			},
+		},
+		AccessRoles: []main.AccessRole{
+			main.AccessRole{
+				Name: "operator",
+				Groups: []string{
+					"inloco:sre",
+				},
+				Inherits: []string{
+					"read-sync",
+				},
+			},
+		},
+	})
+
+	ginkgo.It("is not generated by default", func() {
+		g.Expect(generateConfigMaps(argoCDProject)).To(g.BeEmpty())
+	})
+
+	ginkgo.It("maps groups to project roles", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.RBACConfigMap = &main.RBACConfigMapOutput{}
+
+		configMaps := generateConfigMaps(argoCDProject)
+
+		g.Expect(configMaps).To(g.HaveLen(1))
+		g.Expect(configMaps[0].Name).To(g.Equal("argocd-rbac-cm"))
+		g.Expect(configMaps[0].Namespace).To(g.Equal("argocd"))
+		g.Expect(configMaps[0].Annotations).To(g.HaveKeyWithValue("kustomize.config.k8s.io/behavior", "merge"))
+		g.Expect(configMaps[0].Data).To(g.Equal(map[string]string{
+			"policy.github-checker.csv": "" +
+				"g, inloco:everyone, proj:github-checker:read-only\n" +
+				"g, inloco:github-checker-devs, proj:github-checker:read-sync\n" +
+				"g, inloco:sre, proj:github-checker:operator\n" +
+				"g, inloco:sre, proj:github-checker:read-sync\n",
+		}))
+	})
+
+	ginkgo.It("allows the ConfigMap to be renamed", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.RBACConfigMap = &main.RBACConfigMapOutput{
+			Name:      "rbac",
+			Namespace: "gitops",
+		}
+
+		configMaps := generateConfigMaps(argoCDProject)
+
+		g.Expect(configMaps).To(g.HaveLen(1))
+		g.Expect(configMaps[0].Name).To(g.Equal("rbac"))
+		g.Expect(configMaps[0].Namespace).To(g.Equal("gitops"))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject environments", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AccessControl: main.AppProjectAccessControl{
+			ReadSync: []string{
+				"inloco:sre",
+			},
+		},
+		SyncWindows: []main.SyncWindow{
+			main.SyncWindow{
+				Kind:         "deny",
+				Schedule:     "0 22 * * *",
+				Duration:     "8h",
+				Applications: []string{"github-checker-app"},
+			},
+		},
+		ApplicationTemplates: []argov1alpha1.Application{
+			newApplicationTemplate("github-checker-app", "github-checker"),
+		},
+	})
+
+	ginkgo.It("generates one project per environment", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environments = []main.ProjectEnvironment{
+			main.ProjectEnvironment{
+				Name: "staging",
+			},
+			main.ProjectEnvironment{
+				Name: "production",
+				Destination: &argov1alpha1.ApplicationDestination{
+					Server: "https://kubernetes.default.svc",
+				},
+				AccessControl: main.AppProjectAccessControl{
+					ReadSync: []string{
+						"inloco:github-checker-oncall",
+					},
+				},
+				TargetRevision: "main",
+			},
+		}
+
+		appProjects := generateAppProjects(argoCDProject)
+		g.Expect(appProjects).To(g.HaveLen(2))
+
+		g.Expect(appProjects[0].Name).To(g.Equal("github-checker-staging"))
+		g.Expect(appProjects[0].Spec.Destinations).To(g.Equal([]argov1alpha1.ApplicationDestination{
+			argov1alpha1.ApplicationDestination{
+				Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+				Namespace: "github-checker",
+			},
+		}))
+		g.Expect(appProjects[0].Spec.SyncWindows[0].Applications).To(g.Equal([]string{"github-checker-app-staging"}))
+
+		g.Expect(appProjects[1].Name).To(g.Equal("github-checker-production"))
+		g.Expect(appProjects[1].Spec.Destinations).To(g.Equal([]argov1alpha1.ApplicationDestination{
+			argov1alpha1.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: "github-checker",
+			},
+		}))
+		g.Expect(appProjects[1].Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name":   g.Equal("read-sync"),
+			"Groups": g.Equal([]string{"inloco:github-checker-oncall", "inloco:sre"}),
+		})))
+		g.Expect(appProjects[1].Spec.SyncWindows[0].Applications).To(g.Equal([]string{"github-checker-app-production"}))
+
+		apps := generateApplications(argoCDProject)
+		g.Expect(apps).To(g.HaveLen(2))
+
+		g.Expect(apps[0].Name).To(g.Equal("github-checker-app-staging"))
+		g.Expect(apps[0].Spec.Project).To(g.Equal("github-checker-staging"))
+		g.Expect(apps[0].Spec.Source.Path).To(g.Equal("./k8s/overlays/staging"))
+		g.Expect(apps[0].Spec.Source.TargetRevision).To(g.Equal("env-staging"))
+
+		g.Expect(apps[1].Name).To(g.Equal("github-checker-app-production"))
+		g.Expect(apps[1].Spec.Project).To(g.Equal("github-checker-production"))
+		g.Expect(apps[1].Spec.Source.Path).To(g.Equal("./k8s/overlays/production"))
+		g.Expect(apps[1].Spec.Source.TargetRevision).To(g.Equal("main"))
+		g.Expect(apps[1].Spec.Destination.Server).To(g.Equal("https://kubernetes.default.svc"))
+	})
+
+	ginkgo.It("moves template role policies into each project", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.AppProject = argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				Roles: []argov1alpha1.ProjectRole{
+					argov1alpha1.ProjectRole{
+						Name: "operator",
+						Policies: []string{
+							"p, proj:github-checker:operator, applications, override, github-checker/*, allow",
+							"g, proj:github-checker:operator, proj:github-checker:read-sync",
+						},
+					},
+				},
+			},
+		}
+		argoCDProject.Spec.Environments = []main.ProjectEnvironment{
+			main.ProjectEnvironment{
+				Name: "staging",
+			},
+			main.ProjectEnvironment{
+				Name: "production",
+			},
+		}
+
+		appProjects := generateAppProjects(argoCDProject)
+		g.Expect(appProjects).To(g.HaveLen(2))
+
+		g.Expect(appProjects[0].Spec.Roles).To(g.ContainElement(argov1alpha1.ProjectRole{
+			Name: "operator",
+			Policies: []string{
+				"g, proj:github-checker-staging:operator, proj:github-checker-staging:read-sync",
+				"p, proj:github-checker-staging:operator, applications, override, github-checker-staging/*, allow",
+			},
+		}))
+		g.Expect(appProjects[1].Spec.Roles).To(g.ContainElement(argov1alpha1.ProjectRole{
+			Name: "operator",
+			Policies: []string{
+				"g, proj:github-checker-production:operator, proj:github-checker-production:read-sync",
+				"p, proj:github-checker-production:operator, applications, override, github-checker-production/*, allow",
+			},
+		}))
+	})
+
+	ginkgo.It("rejects duplicate environments", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environments = []main.ProjectEnvironment{
+			main.ProjectEnvironment{
+				Name: "staging",
+			},
+			main.ProjectEnvironment{
+				Name: "staging",
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.environments[1].name")))
+	})
+
+	ginkgo.It("rejects malformed target revisions", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environments = []main.ProjectEnvironment{
+			main.ProjectEnvironment{
+				Name:           "staging",
+				TargetRevision: "{{ .Nope",
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.environments[0].targetRevision")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject cluster catalog", func() {
+	newClusterCatalogArgoCDProject := func(destinationName string) main.ArgoCDProject {
+		catalogFile := filepath.Join(ginkgo.GinkgoT().TempDir(), "clusters.yaml")
+		g.Expect(os.WriteFile(catalogFile, []byte(`
+clusters:
+  - alias: global-sre
+    name: arn:aws:eks:us:123456789876:cluster/Global-SRE
+  - alias: in-cluster
+    server: https://kubernetes.default.svc
+`), 0o644)).To(g.Succeed())
+
+		app := newApplicationTemplate("github-checker-app", "github-checker")
+		app.Spec.Destination.Name = destinationName
+
+		return newArgoCDProject("github-checker", main.ProjectSpec{
+			ClusterCatalogFile: catalogFile,
+			Environments: []main.ProjectEnvironment{
+				main.ProjectEnvironment{
+					Name: "staging",
+				},
+				main.ProjectEnvironment{
+					Name: "production",
+					Destination: &argov1alpha1.ApplicationDestination{
+						Name: "in-cluster",
+					},
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				app,
+			},
+		})
+	}
+
+	ginkgo.It("resolves aliases into destinations", func() {
+		argoCDProject := newClusterCatalogArgoCDProject("global-sre")
+
+		g.Expect(generateApplications(argoCDProject)).To(gstruct.MatchAllElements(func(e interface{}) string {
+			return e.(argov1alpha1.Application).Name
+		}, gstruct.Elements{
+			"github-checker-app-staging": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Destination": g.Equal(argov1alpha1.ApplicationDestination{
+						Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+						Namespace: "github-checker",
+					}),
+				}),
+			}),
+			"github-checker-app-production": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"Destination": g.Equal(argov1alpha1.ApplicationDestination{
+						Server:    "https://kubernetes.default.svc",
+						Namespace: "github-checker",
+					}),
+				}),
+			}),
+		}))
+
+		g.Expect(generateAppProjects(argoCDProject)[1].Spec.Destinations).To(g.Equal([]argov1alpha1.ApplicationDestination{
+			argov1alpha1.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: "github-checker",
+			},
+		}))
+	})
+
+	ginkgo.It("rejects unknown aliases", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newClusterCatalogArgoCDProject("product-staging"))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(`spec.applicationTemplates[0].spec.destination.name: Not found: "product-staging"`)))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject dependencies", func() {
+	newDependenciesArgoCDProject := func(dependencies map[string]string) main.ArgoCDProject {
+		var applicationTemplates []argov1alpha1.Application
+		for _, name := range []string{"github-checker-operator", "github-checker-crs", "github-checker-app", "github-checker-docs"} {
+			app := newApplicationTemplate(name, "github-checker")
+			if dependsOn, ok := dependencies[name]; ok {
+				app.Annotations = map[string]string{
+					"argocdproject.incognia.com/depends-on": dependsOn,
+				}
+			}
+
+			applicationTemplates = append(applicationTemplates, app)
+		}
+
+		return newArgoCDProject("github-checker", main.ProjectSpec{
+			ApplicationTemplates: applicationTemplates,
+		})
+	}
+
+	ginkgo.It("sets sync waves in dependency order", func() {
+		apps := generateApplications(newDependenciesArgoCDProject(map[string]string{
+			"github-checker-crs": "github-checker-operator",
+			"github-checker-app": "github-checker-operator, github-checker-crs",
+		}))
+
+		annotations := make(map[string]map[string]string, len(apps))
+		for _, app := range apps {
+			annotations[app.Name] = app.Annotations
+		}
+
+		g.Expect(annotations).To(g.Equal(map[string]map[string]string{
+			"github-checker-operator": map[string]string{
+				"argocd.argoproj.io/sync-wave": "0",
+			},
+			"github-checker-crs": map[string]string{
+				"argocd.argoproj.io/sync-wave": "1",
+			},
+			"github-checker-app": map[string]string{
+				"argocd.argoproj.io/sync-wave": "2",
+			},
+			"github-checker-docs": nil,
+		}))
+	})
+
+	ginkgo.DescribeTable("rejects invalid dependencies", func(dependencies map[string]string, message string) {
+		argoCDProjectYaml, err := yaml.Marshal(newDependenciesArgoCDProject(dependencies))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(message)))
+	},
+		ginkgo.Entry("unknown application", map[string]string{
+			"github-checker-app": "github-checker-db",
+		}, `Not found: "github-checker-db"`),
+		ginkgo.Entry("cycle", map[string]string{
+			"github-checker-operator": "github-checker-app",
+			"github-checker-crs":      "github-checker-operator",
+			"github-checker-app":      "github-checker-crs",
+		}, "dependency cycle github-checker-operator -> github-checker-app -> github-checker-crs -> github-checker-operator"),
+	)
+})
+
+var _ = ginkgo.Describe("ArgoCDProject parent application", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		ApplicationDefaults: main.ApplicationDefaults{
+			SyncPolicy: &argov1alpha1.SyncPolicy{
+				Automated: &argov1alpha1.SyncPolicyAutomated{},
+			},
+		},
+		ApplicationTemplates: []argov1alpha1.Application{
+			newApplicationTemplate("github-checker-app", "github-checker"),
+		},
+	})
+
+	destinations := []argov1alpha1.ApplicationDestination{
+		argov1alpha1.ApplicationDestination{
+			Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+			Namespace: "github-checker",
+		},
+		argov1alpha1.ApplicationDestination{
+			Server:    "https://kubernetes.default.svc",
+			Namespace: "github-checker-apps",
+		},
+	}
+
+	ginkgo.It("points at the generated applications", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.AppProject.Spec.Destinations = destinations
+		argoCDProject.Spec.ParentApplication = &main.ParentApplicationOutput{
+			Source: argov1alpha1.ApplicationSource{
+				RepoURL:        "https://github.com/inloco/gitops.git",
+				Path:           "projects/github-checker",
+				TargetRevision: "main",
+			},
+			Destination: argov1alpha1.ApplicationDestination{
+				Namespace: "github-checker-apps",
+			},
+		}
+
+		apps := generateApplications(argoCDProject)
+		g.Expect(apps).To(g.HaveLen(2))
+		g.Expect(apps[0].Name).To(g.Equal("github-checker"))
+		g.Expect(apps[0].Spec).To(g.Equal(argov1alpha1.ApplicationSpec{
+			Project: "github-checker",
+			Source: &argov1alpha1.ApplicationSource{
+				RepoURL:        "https://github.com/inloco/gitops.git",
+				Path:           "projects/github-checker",
+				TargetRevision: "main",
+			},
+			Destination: argov1alpha1.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: "github-checker-apps",
+			},
+			SyncPolicy: &argov1alpha1.SyncPolicy{
+				Automated: &argov1alpha1.SyncPolicyAutomated{},
+			},
+		}))
+
+		appProject := generateAppProject(argoCDProject)
+		g.Expect(appProject.Spec.SourceRepos).To(g.Equal([]string{
+			"https://github.com/inloco/github-checker.git",
+			"https://github.com/inloco/gitops.git",
+		}))
+		g.Expect(appProject.Spec.Destinations).To(g.Equal(destinations))
+	})
+
+	ginkgo.It("requires the parent destination to be allowed explicitly", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ParentApplication = &main.ParentApplicationOutput{
+			Source: argov1alpha1.ApplicationSource{
+				RepoURL: "https://github.com/inloco/gitops.git",
+				Path:    "projects/github-checker",
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.parentApplication.destination.namespace: Required value")))
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("parent application destinations require spec.appProjectTemplate.spec.destinations")))
+	})
+
+	ginkgo.It("requires the directory holding the generated applications", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ParentApplication = &main.ParentApplicationOutput{
+			Source: argov1alpha1.ApplicationSource{
+				RepoURL: "https://github.com/inloco/gitops.git",
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.parentApplication.source.path")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject application namespace", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		ApplicationSet: &main.ApplicationSetOutput{},
+		ApplicationTemplates: []argov1alpha1.Application{
+			newApplicationTemplate("github-checker-app", "github-checker"),
+		},
+	})
+
+	ginkgo.It("scopes policies and application sets to the namespace", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ApplicationNamespace = "sre-apps"
+
+		appProject := generateAppProject(argoCDProject)
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name":     g.Equal("read-sync"),
+			"Policies": g.ContainElement("p, proj:github-checker:read-sync, applications, sync, github-checker/sre-apps/*, allow"),
+		})))
+
+		applicationSets := generateApplicationSets(argoCDProject)
+		g.Expect(applicationSets).To(g.HaveLen(1))
+		g.Expect(applicationSets[0].Namespace).To(g.Equal("sre-apps"))
+	})
+
+	ginkgo.It("rejects invalid namespaces", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ApplicationNamespace = "SRE_Apps"
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.applicationNamespace")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject template roles", func() {
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AccessControl: main.AppProjectAccessControl{
+			ReadSync: []string{
+				"inloco:sre",
+			},
+		},
+		AppProject: argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				Roles: []argov1alpha1.ProjectRole{
+					argov1alpha1.ProjectRole{
+						Name: "read-sync",
+						Groups: []string{
+							"inloco:sre",
+							"inloco:github-checker-devs",
+						},
+						Policies: []string{
+							"p, proj:github-checker:read-sync, applications, delete, github-checker/*, allow",
+						},
+					},
+					argov1alpha1.ProjectRole{
+						Name: "auditor",
+					},
+				},
+			},
+		},
+	})
+
+	ginkgo.It("rejects collisions with generated roles", func() {
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(`spec.appProjectTemplate.spec.roles[0].name: Invalid value: "read-sync"`)))
+	})
+
+	ginkgo.It("merges template roles into generated ones", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.MergeTemplateRoles = true
+
+		appProject := generateAppProject(argoCDProject)
+
+		g.Expect(appProject.Spec.Roles).To(gstruct.MatchAllElements(func(e interface{}) string {
+			return e.(argov1alpha1.ProjectRole).Name
+		}, gstruct.Elements{
+			"auditor":   g.Equal(argov1alpha1.ProjectRole{Name: "auditor"}),
+			"read-only": g.Not(g.BeZero()),
+			"read-sync": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Groups": g.Equal([]string{"inloco:github-checker-devs", "inloco:sre"}),
+				"Policies": g.And(
+					g.ContainElements(main.ReadSync.Policies("github-checker", "")),
+					g.ContainElement("p, proj:github-checker:read-sync, applications, delete, github-checker/*, allow"),
+					g.HaveLen(len(main.ReadSync.Policies("github-checker", ""))+1),
+				),
+			}),
+		}))
+	})
+
+	ginkgo.It("grants environment actions once to merged roles", func() {
+		overridePolicy := "p, proj:github-checker:read-sync, applications, override, github-checker/*, allow"
+
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environment = "staging"
+		argoCDProject.Spec.MergeTemplateRoles = true
+		argoCDProject.Spec.AppProject = argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				Roles: []argov1alpha1.ProjectRole{
+					argov1alpha1.ProjectRole{
+						Name: "read-sync",
+						Policies: []string{
+							overridePolicy,
+						},
+					},
+				},
+			},
+		}
+
+		appProject := generateAppProject(argoCDProject)
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name": g.Equal("read-sync"),
+			"Policies": g.And(
+				g.ContainElement(overridePolicy),
+				g.HaveLen(len(main.ReadSync.Policies("github-checker", ""))+1),
+			),
+		})))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject conversion", func() {
+	app := newApplicationTemplate("github-checker-app", "github-checker")
+	app.Spec.Source.Path = "k8s"
+	app.Spec.Source.TargetRevision = "main"
+
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		AccessControl: main.AppProjectAccessControl{
+			ReadOnly: []string{
+				"inloco:everyone",
+			},
+			ReadSync: []string{
+				"inloco:sre",
+			},
+		},
+		AccessRoles: []main.AccessRole{
+			main.AccessRole{
+				Name: "operator",
+				Groups: []string{
+					"inloco:sre",
+				},
+				Permissions: []main.AccessPermission{
+					main.AccessPermission{
+						Actions: []string{"delete"},
+					},
+				},
+			},
+		},
+		ApplicationNamespace: "sre-apps",
+		SyncWindows: []main.SyncWindow{
+			main.SyncWindow{
+				Kind:     "deny",
+				Schedule: "0 22 * * *",
+				Duration: "8h",
+			},
+		},
+		AppProject: argov1alpha1.AppProject{
+			Spec: argov1alpha1.AppProjectSpec{
+				Roles: []argov1alpha1.ProjectRole{
+					argov1alpha1.ProjectRole{
+						Name: "read-sync",
+						Policies: []string{
+							"p, proj:github-checker:read-sync, applications, override, github-checker/sre-apps/*, allow",
+						},
+					},
+				},
+			},
+		},
+		MergeTemplateRoles: true,
+		ApplicationTemplates: []argov1alpha1.Application{
+			app,
+		},
+	})
+
+	ginkgo.It("round-trips generated manifests", func() {
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		var generated bytes.Buffer
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, &generated)).To(g.Succeed())
+
+		var converted bytes.Buffer
+		g.Expect(main.ConvertManifests(generated.Bytes(), &converted)).To(g.Succeed())
+
+		var convertedArgoCDProject main.ArgoCDProject
+		g.Expect(yaml.Unmarshal(converted.Bytes(), &convertedArgoCDProject)).To(g.Succeed())
+		g.Expect(convertedArgoCDProject.Spec.AccessControl).To(g.Equal(argoCDProject.Spec.AccessControl))
+		g.Expect(convertedArgoCDProject.Spec.ApplicationNamespace).To(g.Equal("sre-apps"))
+		g.Expect(convertedArgoCDProject.Spec.AppProject.Spec.SourceRepos).To(g.BeNil())
+		g.Expect(convertedArgoCDProject.Spec.AppProject.Spec.Destinations).To(g.BeNil())
+
+		var regenerated bytes.Buffer
+		g.Expect(main.GenerateManifests(converted.Bytes(), &regenerated)).To(g.Succeed())
+		g.Expect(regenerated.String()).To(g.Equal(generated.String()))
+	})
+
+	ginkgo.It("infers the environment from the conventions", func() {
+		argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+			AccessControl: main.AppProjectAccessControl{
+				ReadSync: []string{
+					"inloco:sre",
+				},
+			},
+			Environment: "staging",
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+			},
+		})
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		var generated bytes.Buffer
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, &generated)).To(g.Succeed())
+
+		var converted bytes.Buffer
+		g.Expect(main.ConvertManifests(generated.Bytes(), &converted)).To(g.Succeed())
+		g.Expect(converted.String()).NotTo(g.ContainSubstring("creationTimestamp"))
+		g.Expect(converted.String()).NotTo(g.ContainSubstring("project:"))
+		g.Expect(converted.String()).NotTo(g.ContainSubstring("{}"))
+
+		var convertedArgoCDProject main.ArgoCDProject
+		g.Expect(yaml.Unmarshal(converted.Bytes(), &convertedArgoCDProject)).To(g.Succeed())
+		g.Expect(convertedArgoCDProject.Spec.Environment).To(g.Equal("staging"))
+		g.Expect(convertedArgoCDProject.Spec.ApplicationTemplates[0].Spec.Source.Path).To(g.BeEmpty())
+		g.Expect(convertedArgoCDProject.Spec.ApplicationTemplates[0].Spec.Source.TargetRevision).To(g.BeEmpty())
+		g.Expect(convertedArgoCDProject.Spec.AppProject.Spec.Roles).To(g.BeEmpty())
+
+		var regenerated bytes.Buffer
+		g.Expect(main.GenerateManifests(converted.Bytes(), &regenerated)).To(g.Succeed())
+		g.Expect(regenerated.String()).To(g.Equal(generated.String()))
+	})
+
+	ginkgo.It("rejects applications from other projects", func() {
+		g.Expect(main.ConvertManifests([]byte(`
+apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: github-checker
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: another-checker-app
+spec:
+  project: another-checker
+`), io.Discard)).NotTo(g.Succeed())
+	})
+
+	ginkgo.DescribeTable("rejects application namespaces it can not keep", func(manifests string, message string) {
+		err := main.ConvertManifests([]byte(manifests), io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(message)))
+	},
+		ginkgo.Entry("with several namespaces", `
+apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: github-checker
+spec:
+  sourceNamespaces:
+    - sre-apps
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: github-checker-app
+  namespace: sre-apps
+spec:
+  project: github-checker
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: github-checker-worker
+  namespace: argocd
+spec:
+  project: github-checker
+`, `application github-checker-worker is in namespace "argocd" instead of "sre-apps"`),
+		ginkgo.Entry("with a namespace outside the source namespaces", `
+apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: github-checker
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: github-checker-app
+  namespace: argocd
+spec:
+  project: github-checker
+`, "application namespace argocd is not one of the project source namespaces"),
+	)
+})
+
+var _ = ginkgo.Describe("ArgoCDProject notifications", func() {
+	ginkgo.It("subscribes applications to triggers", func() {
+		app := newApplicationTemplate("github-checker-app", "github-checker")
+		app.Annotations = map[string]string{
+			"notifications.argoproj.io/subscribe.on-deployed.email": "",
+		}
+
+		apps := generateApplications(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "production",
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name: "production",
+					Notifications: []main.Notification{
+						main.Notification{
+							Trigger:    "on-health-degraded",
+							Service:    "slack",
+							Recipients: []string{"sre-alerts"},
+						},
+						main.Notification{
+							Trigger:    "on-sync-failed",
+							Service:    "slack",
+							Recipients: []string{"sre-alerts"},
+						},
+					},
+				},
+			},
+			Notifications: []main.Notification{
+				main.Notification{
+					Trigger:    "on-sync-failed",
+					Service:    "slack",
+					Recipients: []string{"github-checker", "sre-alerts"},
+				},
+				main.Notification{
+					Trigger:    "on-deployed",
+					Service:    "email",
+					Recipients: []string{"github-checker@incognia.com"},
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				app,
+			},
+		}))
+
+		g.Expect(apps).To(g.HaveLen(1))
+		g.Expect(apps[0].Annotations).To(g.Equal(map[string]string{
+			"notifications.argoproj.io/subscribe.on-deployed.email":        "",
+			"notifications.argoproj.io/subscribe.on-health-degraded.slack": "sre-alerts",
+			"notifications.argoproj.io/subscribe.on-sync-failed.slack":     "sre-alerts",
+		}))
+	})
+
+	ginkgo.It("rejects subscriptions without recipients", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			Notifications: []main.Notification{
+				main.Notification{
+					Trigger: "on-sync-failed",
+					Service: "slack",
+				},
+			},
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.notifications[0].recipients")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject namespace metadata", func() {
+	ginkgo.It("manages the metadata of owned namespaces", func() {
+		apps := generateApplications(newArgoCDProject("github-checker", main.ProjectSpec{
+			ApplicationDefaults: main.ApplicationDefaults{
+				SyncPolicy: &argov1alpha1.SyncPolicy{
+					SyncOptions: argov1alpha1.SyncOptions{
+						"ServerSideApply=true",
+					},
+				},
+			},
+			NamespaceMetadata: &main.NamespaceMetadata{
+				Namespaces: []string{
+					"github-checker",
+				},
+				Labels: map[string]string{
+					"pod-security.kubernetes.io/enforce": "restricted",
+				},
+				Annotations: map[string]string{
+					"incognia.com/cost-center": "sre",
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+				newApplicationTemplate("github-checker-monitoring", "monitoring"),
+			},
+		}))
+
+		g.Expect(apps).To(gstruct.MatchAllElements(func(e interface{}) string {
+			return e.(argov1alpha1.Application).Name
+		}, gstruct.Elements{
+			"github-checker-app": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"SyncPolicy": g.Equal(&argov1alpha1.SyncPolicy{
+						SyncOptions: argov1alpha1.SyncOptions{
+							"ServerSideApply=true",
+							"CreateNamespace=true",
+						},
+						ManagedNamespaceMetadata: &argov1alpha1.ManagedNamespaceMetadata{
+							Labels: map[string]string{
+								"pod-security.kubernetes.io/enforce": "restricted",
+							},
+							Annotations: map[string]string{
+								"incognia.com/cost-center": "sre",
+							},
+						},
+					}),
+				}),
+			}),
+			"github-checker-monitoring": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+					"SyncPolicy": g.Equal(&argov1alpha1.SyncPolicy{
+						SyncOptions: argov1alpha1.SyncOptions{
+							"ServerSideApply=true",
+						},
+					}),
+				}),
+			}),
+		}))
+	})
+
+	ginkgo.It("requires the owned namespaces", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			NamespaceMetadata: &main.NamespaceMetadata{
+				Labels: map[string]string{
+					"pod-security.kubernetes.io/enforce": "restricted",
+				},
+			},
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.namespaceMetadata.namespaces")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject signature keys", func() {
+	newSignatureKeysArgoCDProject := func(signatureKeys ...string) main.ArgoCDProject {
+		return newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "production",
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name:      "production",
+					Protected: true,
+				},
+			},
+			SignatureKeys: signatureKeys,
+		})
+	}
+
+	ginkgo.It("requires commits to be signed", func() {
+		appProject := generateAppProject(newSignatureKeysArgoCDProject("4AEE18F83AFDEB23"))
+
+		g.Expect(appProject.Spec.SignatureKeys).To(g.Equal([]argov1alpha1.SignatureKey{
+			argov1alpha1.SignatureKey{
+				KeyID: "4AEE18F83AFDEB23",
+			},
+		}))
+	})
+
+	ginkgo.DescribeTable("rejects invalid signature keys", func(argoCDProject main.ArgoCDProject, message string) {
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(message)))
+	},
+		ginkgo.Entry("in protected environment without keys", newSignatureKeysArgoCDProject(), "spec.signatureKeys: Required value: environment production is protected"),
+		ginkgo.Entry("with short key ID", newSignatureKeysArgoCDProject("3AFDEB23"), "spec.signatureKeys[0]"),
+	)
+})
+
+var _ = ginkgo.Describe("ArgoCDProject policy linter", func() {
+	newPolicyArgoCDProject := func(environment string, policies ...string) main.ArgoCDProject {
+		return newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: environment,
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name: "qa",
+				},
+				main.EnvironmentProfile{
+					Name:      "production",
+					Protected: true,
+				},
+			},
+			SignatureKeys: []string{
+				"4AEE18F83AFDEB23",
+			},
+			AppProject: argov1alpha1.AppProject{
+				Spec: argov1alpha1.AppProjectSpec{
+					Roles: []argov1alpha1.ProjectRole{
+						argov1alpha1.ProjectRole{
+							Name:     "operator",
+							Policies: policies,
+						},
+					},
+				},
+			},
+		})
+	}
+
+	policyEnvironments := []main.ProjectEnvironment{
+		main.ProjectEnvironment{
+			Name: "qa",
+		},
+		main.ProjectEnvironment{
+			Name: "production",
+		},
+	}
+
+	ginkgo.It("accepts policies within the project", func() {
+		appProject := generateAppProject(newPolicyArgoCDProject("production",
+			"p, proj:github-checker:operator, applications, override, github-checker/*, allow",
+			"p, proj:github-checker:operator, applications, delete, github-checker/*, deny",
+			"g, proj:github-checker:operator, proj:github-checker:read-sync",
+		))
+
+		g.Expect(appProject.Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name": g.Equal("operator"),
+		})))
+	})
+
+	ginkgo.DescribeTable("rejects invalid policies", func(environment string, policy string, message string) {
+		argoCDProjectYaml, err := yaml.Marshal(newPolicyArgoCDProject(environment, policy))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.appProjectTemplate.spec.roles[0].policies[0]")))
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(message)))
+	},
+		ginkgo.Entry("with missing fields", "qa", "p, proj:github-checker:operator, applications, sync, github-checker/*", "must have 6 fields"),
+		ginkgo.Entry("with unknown type", "qa", "x, proj:github-checker:operator, applications", "unknown policy type"),
+		ginkgo.Entry("with unknown effect", "qa", "p, proj:github-checker:operator, applications, sync, github-checker/*, alow", "unknown effect"),
+		ginkgo.Entry("with another role as subject", "qa", "p, proj:github-checker:read-sync, applications, sync, github-checker/*, allow", "subject must be proj:github-checker:operator"),
+		ginkgo.Entry("with any project as object", "qa", "p, proj:github-checker:operator, applications, sync, */*, allow", "escapes project"),
+		ginkgo.Entry("with another project as object", "qa", "p, proj:github-checker:operator, applications, sync, another-checker/*, allow", "escapes project"),
+		ginkgo.Entry("with another project role", "qa", "g, proj:github-checker:operator, proj:another-checker:read-sync", "escapes project"),
+		ginkgo.Entry("with delete in protected environment", "production", "p, proj:github-checker:operator, applications, delete, github-checker/*, allow", "delete and exec may not be granted"),
+		ginkgo.Entry("with exec in protected environment", "production", "p, proj:github-checker:operator, exec, create, github-checker/*, allow", "delete and exec may not be granted"),
+	)
+
+	ginkgo.It("accepts policies within every environment project", func() {
+		argoCDProject := newPolicyArgoCDProject("",
+			"p, proj:github-checker:operator, applications, override, github-checker/*, allow",
+			"g, proj:github-checker:operator, proj:github-checker:read-sync",
+		)
+		argoCDProject.Spec.Environments = policyEnvironments
+
+		appProjects := generateAppProjects(argoCDProject)
+		g.Expect(appProjects).To(g.HaveLen(2))
+		g.Expect(appProjects[1].Spec.Roles).To(g.ContainElement(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+			"Name":     g.Equal("operator"),
+			"Policies": g.ContainElement("p, proj:github-checker-production:operator, applications, override, github-checker-production/*, allow"),
+		})))
+	})
+
+	ginkgo.DescribeTable("rejects destructive actions of protected environment profiles", func(environment string, environments []main.ProjectEnvironment, path string) {
+		argoCDProject := newPolicyArgoCDProject(environment)
+		argoCDProject.Spec.Environments = environments
+		argoCDProject.Spec.EnvironmentProfiles[1].Actions = []string{"delete", "exec"}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(path + ": Forbidden: environment production is protected, its profile may not grant delete or exec to read-sync")))
+	},
+		ginkgo.Entry("with environment", "production", nil, "spec.environment"),
+		ginkgo.Entry("with environments", "", policyEnvironments, "spec.environments[1].name"),
+	)
+
+	ginkgo.DescribeTable("rejects policies escaping an environment project", func(policy string, message string) {
+		argoCDProject := newPolicyArgoCDProject("", policy)
+		argoCDProject.Spec.Environments = policyEnvironments
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.appProjectTemplate.spec.roles[0].policies[0]")))
+		g.Expect(err).To(g.MatchError(g.ContainSubstring(message)))
+	},
+		ginkgo.Entry("with another environment as subject", "p, proj:github-checker-production:operator, applications, sync, github-checker-production/*, allow", "subject must be proj:github-checker-qa:operator"),
+		ginkgo.Entry("with another environment as object", "p, proj:github-checker:operator, applications, sync, github-checker-production/*, allow", "object github-checker-production/* escapes project github-checker-qa"),
+		ginkgo.Entry("with delete in protected environment", "p, proj:github-checker:operator, applications, delete, github-checker/*, allow", "delete and exec may not be granted"),
 	)
 })
 
+var _ = ginkgo.Describe("ArgoCDProject ignored differences and orphaned resources", func() {
+	warn := true
+
+	hpaReplicas := argov1alpha1.ResourceIgnoreDifferences{
+		Group:        "apps",
+		Kind:         "Deployment",
+		JSONPointers: []string{"/spec/replicas"},
+	}
+	webhookCABundle := argov1alpha1.ResourceIgnoreDifferences{
+		Group:             "admissionregistration.k8s.io",
+		Kind:              "MutatingWebhookConfiguration",
+		JQPathExpressions: []string{".webhooks[]?.clientConfig.caBundle"},
+	}
+
+	app := newApplicationTemplate("github-checker-app", "github-checker")
+	app.Spec.IgnoreDifferences = argov1alpha1.IgnoreDifferences{
+		hpaReplicas,
+	}
+
+	argoCDProject := newArgoCDProject("github-checker", main.ProjectSpec{
+		EnvironmentProfiles: []main.EnvironmentProfile{
+			main.EnvironmentProfile{
+				Name: "qa",
+			},
+			main.EnvironmentProfile{
+				Name: "production",
+				ApplicationDefaults: main.ApplicationDefaults{
+					IgnoreDifferences: argov1alpha1.IgnoreDifferences{
+						webhookCABundle,
+					},
+				},
+				OrphanedResources: &argov1alpha1.OrphanedResourcesMonitorSettings{
+					Warn: &warn,
+				},
+			},
+		},
+		ApplicationDefaults: main.ApplicationDefaults{
+			IgnoreDifferences: argov1alpha1.IgnoreDifferences{
+				hpaReplicas,
+			},
+		},
+		OrphanedResources: &argov1alpha1.OrphanedResourcesMonitorSettings{
+			Ignore: []argov1alpha1.OrphanedResourceKey{
+				argov1alpha1.OrphanedResourceKey{
+					Kind: "ConfigMap",
+					Name: "kube-root-ca.crt",
+				},
+			},
+		},
+		ApplicationTemplates: []argov1alpha1.Application{
+			app,
+		},
+	})
+
+	ginkgo.It("applies project defaults", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environment = "qa"
+
+		apps := generateApplications(argoCDProject)
+		g.Expect(apps).To(g.HaveLen(1))
+		g.Expect(apps[0].Spec.IgnoreDifferences).To(g.Equal(argov1alpha1.IgnoreDifferences{
+			hpaReplicas,
+		}))
+
+		g.Expect(generateAppProject(argoCDProject).Spec.OrphanedResources).To(g.Equal(argoCDProject.Spec.OrphanedResources))
+	})
+
+	ginkgo.It("applies environment profile defaults", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.Environment = "production"
+
+		apps := generateApplications(argoCDProject)
+		g.Expect(apps).To(g.HaveLen(1))
+		g.Expect(apps[0].Spec.IgnoreDifferences).To(g.Equal(argov1alpha1.IgnoreDifferences{
+			hpaReplicas,
+			webhookCABundle,
+		}))
+
+		g.Expect(generateAppProject(argoCDProject).Spec.OrphanedResources).To(g.Equal(&argov1alpha1.OrphanedResourcesMonitorSettings{
+			Warn: &warn,
+		}))
+	})
+
+	ginkgo.It("rejects rules without fields", func() {
+		argoCDProject := argoCDProject
+		argoCDProject.Spec.ApplicationDefaults.IgnoreDifferences = argov1alpha1.IgnoreDifferences{
+			argov1alpha1.ResourceIgnoreDifferences{
+				Kind: "Deployment",
+			},
+		}
+
+		argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.MatchError(g.ContainSubstring("spec.applicationDefaults.ignoreDifferences[0]")))
+	})
+})
+
+var _ = ginkgo.Describe("ArgoCDProject validation", func() {
+	ginkgo.It("reports every problem with its field path", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			Environment: "qa",
+			EnvironmentProfiles: []main.EnvironmentProfile{
+				main.EnvironmentProfile{
+					Name: "sandbox",
+				},
+			},
+			AccessRoles: []main.AccessRole{
+				main.AccessRole{
+					Name: "read-only",
+				},
+			},
+			ApplicationTemplates: []argov1alpha1.Application{
+				newApplicationTemplate("github-checker-app", "github-checker"),
+				argov1alpha1.Application{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "github-checker-app",
+					},
+					Spec: argov1alpha1.ApplicationSpec{
+						Destination: argov1alpha1.ApplicationDestination{
+							Namespace: "github-checker",
+						},
+					},
+				},
+			},
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		err = main.GenerateManifests(argoCDProjectYaml, io.Discard)
+		g.Expect(err).To(g.HaveOccurred())
+		g.Expect(err.Error()).To(g.SatisfyAll(
+			g.ContainSubstring("spec.environment: Unsupported value: \"qa\""),
+			g.ContainSubstring("spec.accessRoles[0].name: Invalid value: \"read-only\""),
+			g.ContainSubstring("spec.applicationTemplates[1].metadata.name: Duplicate value: \"github-checker-app\""),
+			g.ContainSubstring("spec.applicationTemplates[1].spec.destination: Required value"),
+			g.ContainSubstring("spec.applicationTemplates[1].spec.source: Required value"),
+		))
+	})
+
+	ginkgo.It("does not write anything on failure", func() {
+		argoCDProjectYaml, err := yaml.Marshal(newArgoCDProject("github-checker", main.ProjectSpec{
+			ApplicationTemplates: []argov1alpha1.Application{
+				argov1alpha1.Application{},
+			},
+		}))
+		g.Expect(err).To(g.BeNil())
+
+		var out bytes.Buffer
+		g.Expect(main.GenerateManifests(argoCDProjectYaml, &out)).NotTo(g.Succeed())
+		g.Expect(out.Len()).To(g.BeZero())
+	})
+})
+
 func ArgoCDProject(argoCDProject main.ArgoCDProject) {
 	var argoCDProjectYaml []byte
 	if data, err := yaml.Marshal(argoCDProject); g.Expect(err).To(g.BeNil()) {
@@ -198,35 +2222,84 @@ func ArgoCDProject(argoCDProject main.ArgoCDProject) {
 				destinations = append(destinations, destination)
 			}
 		}
+		sourceRepos := argoCDProject.Spec.AppProject.Spec.SourceRepos
+		if sourceRepos == nil {
+			if argoCDProject.Spec.AllowAnySourceRepo {
+				sourceRepos = []string{"*"}
+			} else {
+				sourceRepoMap := make(map[string]struct{})
+				for _, applicationTemplate := range argoCDProject.Spec.ApplicationTemplates {
+					for _, source := range applicationTemplate.Spec.GetSources() {
+						sourceRepoMap[source.RepoURL] = struct{}{}
+					}
+				}
+
+				sourceRepos = make([]string, 0, len(sourceRepoMap))
+				for sourceRepo := range sourceRepoMap {
+					sourceRepos = append(sourceRepos, sourceRepo)
+				}
+			}
+		}
+		specSourceReposMatcher := g.And(
+			g.ContainElements(sourceRepos),
+			g.HaveLen(len(sourceRepos)),
+		)
+
 		specDestinationsMatcher := g.And(
 			g.ContainElements(destinations),
 			g.HaveLen(len(destinations)),
 		)
 
+		specSourceNamespacesMatcher := g.BeEmpty()
+		if applicationNamespace := argoCDProject.Spec.ApplicationNamespace; applicationNamespace != "" {
+			specSourceNamespacesMatcher = g.Equal([]string{applicationNamespace})
+		}
+
+		specNamespaceResourceWhitelistMatcher := g.Equal([]metav1.GroupKind{{
+			Group: "*",
+			Kind:  "*",
+		}})
+		if namespaceResourceWhitelist := argoCDProject.Spec.AppProject.Spec.NamespaceResourceWhitelist; len(namespaceResourceWhitelist) > 0 {
+			specNamespaceResourceWhitelistMatcher = g.Equal(namespaceResourceWhitelist)
+		}
+
+		specRolesElements := gstruct.Elements{
+			main.ReadOnly.String(): gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Groups":   g.ContainElements(argoCDProject.Spec.AccessControl.ReadOnly),
+				"Policies": g.ContainElements(main.ReadOnly.Policies(argoCDProject.Name, argoCDProject.Spec.ApplicationNamespace)),
+			}),
+			main.ReadSync.String(): gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Groups":   g.ContainElements(argoCDProject.Spec.AccessControl.ReadSync),
+				"Policies": g.ContainElements(main.ReadSync.Policies(argoCDProject.Name, argoCDProject.Spec.ApplicationNamespace)),
+			}),
+		}
+		if argoCDProject.Spec.CIRole != nil {
+			specRolesElements[main.CISync.String()] = gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Groups":    g.BeEmpty(),
+				"Policies":  g.ConsistOf(main.CISync.Policies(argoCDProject.Name, argoCDProject.Spec.ApplicationNamespace)),
+				"JWTTokens": g.Equal(argoCDProject.Spec.CIRole.JWTTokens),
+			})
+		}
+		for _, accessRole := range argoCDProject.Spec.AccessRoles {
+			specRolesElements[accessRole.Name] = gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
+				"Groups":   g.ConsistOf(accessRole.Groups),
+				"Policies": g.ConsistOf(accessRole.Policies(argoCDProject.Name, argoCDProject.Spec.ApplicationNamespace)),
+			})
+		}
+
 		g.Expect(appProject).To(gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
 			"ObjectMeta": g.Equal(argoCDProject.ObjectMeta),
 			"Spec": gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
-				"SourceRepos": g.Equal([]string{
-					"*",
-				}),
-				"Destinations":             specDestinationsMatcher,
-				"ClusterResourceWhitelist": g.Equal(argoCDProject.Spec.AppProject.Spec.ClusterResourceWhitelist),
-				"NamespaceResourceWhitelist": g.Equal([]metav1.GroupKind{{
-					Group: "*",
-					Kind:  "*",
-				}}),
+				"SourceRepos":                specSourceReposMatcher,
+				"Destinations":               specDestinationsMatcher,
+				"SourceNamespaces":           specSourceNamespacesMatcher,
+				"ClusterResourceWhitelist":   g.Equal(argoCDProject.Spec.AppProject.Spec.ClusterResourceWhitelist),
+				"NamespaceResourceWhitelist": specNamespaceResourceWhitelistMatcher,
+				"NamespaceResourceBlacklist": g.ContainElements(argoCDProject.Spec.AppProject.Spec.NamespaceResourceBlacklist),
+				"ClusterResourceBlacklist":   g.ContainElements(argoCDProject.Spec.AppProject.Spec.ClusterResourceBlacklist),
 				"Roles": gstruct.MatchAllElements(func(e interface{}) string {
 					return e.(argov1alpha1.ProjectRole).Name
-				}, gstruct.Elements{
-					main.ReadOnly.String(): gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
-						"Groups":   g.ContainElements(argoCDProject.Spec.AccessControl.ReadOnly),
-						"Policies": g.ContainElements(main.ReadOnly.Policies(argoCDProject.Name, argoCDProject.Spec.Environment)),
-					}),
-					main.ReadSync.String(): gstruct.MatchFields(gstruct.IgnoreExtras, gstruct.Fields{
-						"Groups":   g.ContainElements(argoCDProject.Spec.AccessControl.ReadSync),
-						"Policies": g.ContainElements(main.ReadSync.Policies(argoCDProject.Name, argoCDProject.Spec.Environment)),
-					}),
-				}),
+				}, specRolesElements),
 			}),
 		}))
 	})
@@ -253,6 +2326,7 @@ func ArgoCDProject(argoCDProject main.ArgoCDProject) {
 					break
 				}
 			}
+			argoCdProjectApp.Namespace = argoCDProject.Spec.ApplicationNamespace
 
 			specSourcePathMatcher := g.Equal(argoCdProjectApp.Spec.Source.Path)
 			specSourceTargetRevisionMatcher := g.Equal(argoCdProjectApp.Spec.Source.TargetRevision)
@@ -288,3 +2362,87 @@ func ArgoCDProject(argoCDProject main.ArgoCDProject) {
 		})
 	})
 }
+
+func newArgoCDProject(name string, spec main.ProjectSpec) main.ArgoCDProject {
+	return main.ArgoCDProject{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: schema.GroupVersion{
+				Group:   "incognia.com",
+				Version: "v1alpha1",
+			}.String(),
+			Kind: "ArgoCDProject",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: spec,
+	}
+}
+
+// newApplicationTemplate returns an application deploying the repository named after its namespace to the Global-SRE
+// cluster.
+func newApplicationTemplate(name string, namespace string) argov1alpha1.Application {
+	return argov1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: argov1alpha1.ApplicationSpec{
+			Source: &argov1alpha1.ApplicationSource{
+				RepoURL: "https://github.com/inloco/" + namespace + ".git",
+			},
+			Destination: argov1alpha1.ApplicationDestination{
+				Name:      "arn:aws:eks:us:123456789876:cluster/Global-SRE",
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+func generateManifests(argoCDProject main.ArgoCDProject) []string {
+	argoCDProjectYaml, err := yaml.Marshal(argoCDProject)
+	g.Expect(err).To(g.BeNil())
+
+	var out bytes.Buffer
+	g.Expect(main.GenerateManifests(argoCDProjectYaml, &out)).To(g.Succeed())
+
+	return separatorYaml.Split(out.String(), -1)
+}
+
+func generateAppProjects(argoCDProject main.ArgoCDProject) []argov1alpha1.AppProject {
+	return generateObjects[argov1alpha1.AppProject](argoCDProject, argov1alpha1.AppProjectSchemaGroupVersionKind)
+}
+
+func generateAppProject(argoCDProject main.ArgoCDProject) argov1alpha1.AppProject {
+	appProjects := generateAppProjects(argoCDProject)
+	g.Expect(appProjects).NotTo(g.BeEmpty())
+
+	return appProjects[0]
+}
+
+func generateApplications(argoCDProject main.ArgoCDProject) []argov1alpha1.Application {
+	return generateObjects[argov1alpha1.Application](argoCDProject, argov1alpha1.ApplicationSchemaGroupVersionKind)
+}
+
+func generateApplicationSets(argoCDProject main.ArgoCDProject) []argov1alpha1.ApplicationSet {
+	return generateObjects[argov1alpha1.ApplicationSet](argoCDProject, argov1alpha1.ApplicationSetSchemaGroupVersionKind)
+}
+
+func generateConfigMaps(argoCDProject main.ArgoCDProject) []corev1.ConfigMap {
+	return generateObjects[corev1.ConfigMap](argoCDProject, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+}
+
+func generateObjects[T any](argoCDProject main.ArgoCDProject, gvk schema.GroupVersionKind) []T {
+	var objects []T
+	for _, manifest := range generateManifests(argoCDProject) {
+		var meta metav1.TypeMeta
+		g.Expect(yaml.Unmarshal([]byte(manifest), &meta)).To(g.Succeed())
+
+		if meta.GroupVersionKind() == gvk {
+			var object T
+			g.Expect(yaml.Unmarshal([]byte(manifest), &object)).To(g.Succeed())
+			objects = append(objects, object)
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+type Cluster struct {
+	Alias  string `json:"alias,omitempty"`
+	Server string `json:"server,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+func (c *Cluster) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if c.Alias == "" {
+		allErrs = append(allErrs, field.Required(path.Child("alias"), ""))
+	}
+
+	if (c.Server == "") == (c.Name == "") {
+		allErrs = append(allErrs, field.Invalid(path, c.Alias, "exactly one of server or name is required"))
+	}
+
+	return allErrs
+}
+
+type ClusterCatalogFile struct {
+	Clusters []Cluster `json:"clusters,omitempty"`
+}
+
+type clusterCatalog map[string]Cluster
+
+func loadClusterCatalog(argocdProject *ArgoCDProject) (clusterCatalog, error) {
+	filePath := argocdProject.Spec.ClusterCatalogFile
+	if filePath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(resolveFilePath(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterCatalogFile ClusterCatalogFile
+	if err := yaml.Unmarshal(data, &clusterCatalogFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	var allErrs field.ErrorList
+	catalog := make(clusterCatalog, len(clusterCatalogFile.Clusters))
+	for i, cluster := range clusterCatalogFile.Clusters {
+		clusterPath := field.NewPath("clusters").Index(i)
+
+		allErrs = append(allErrs, cluster.Validate(clusterPath)...)
+		if _, ok := catalog[cluster.Alias]; ok {
+			allErrs = append(allErrs, field.Duplicate(clusterPath.Child("alias"), cluster.Alias))
+		}
+		catalog[cluster.Alias] = cluster
+	}
+	if len(allErrs) > 0 {
+		return nil, fmt.Errorf("%s: %w", filePath, allErrs.ToAggregate())
+	}
+
+	return catalog, nil
+}
+
+func (c clusterCatalog) validateDestination(destination *argov1alpha1.ApplicationDestination, path *field.Path) field.ErrorList {
+	if c == nil || destination.Name == "" {
+		return nil
+	}
+
+	if _, ok := c[destination.Name]; !ok {
+		return field.ErrorList{
+			field.NotFound(path.Child("name"), destination.Name),
+		}
+	}
+
+	return nil
+}
+
+func (c clusterCatalog) resolveDestination(destination *argov1alpha1.ApplicationDestination) {
+	cluster, ok := c[destination.Name]
+	if !ok {
+		return
+	}
+
+	destination.Server = cluster.Server
+	destination.Name = cluster.Name
+}
+
+func resolveClusterAliases(argocdProject *ArgoCDProject, catalog clusterCatalog) {
+	if catalog == nil {
+		return
+	}
+
+	for i := range argocdProject.Spec.ApplicationTemplates {
+		catalog.resolveDestination(&argocdProject.Spec.ApplicationTemplates[i].Spec.Destination)
+	}
+
+	if argocdProject.Spec.ApplicationSet != nil {
+		catalog.resolveDestination(&argocdProject.Spec.ApplicationSet.Template.Spec.Destination)
+	}
+
+	if argocdProject.Spec.ParentApplication != nil {
+		catalog.resolveDestination(&argocdProject.Spec.ParentApplication.Destination)
+	}
+}
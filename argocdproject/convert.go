@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	convertCommand = "convert"
+
+	argocdProjectAPIVersion = "incognia.com/v1alpha1"
+	argocdProjectKind       = "ArgoCDProject"
+)
+
+func ConvertManifests(data []byte, out io.Writer) error {
+	appProject, apps, err := decodeManifests(data)
+	if err != nil {
+		return err
+	}
+
+	argocdProject, err := convertManifests(appProject, apps)
+	if err != nil {
+		return err
+	}
+
+	b, err := marshalArgoCDProject(argocdProject)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(b)
+	return err
+}
+
+func decodeManifests(data []byte) (*argov1alpha1.AppProject, []argov1alpha1.Application, error) {
+	var appProject *argov1alpha1.AppProject
+	var apps []argov1alpha1.Application
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		manifest, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var meta metav1.TypeMeta
+		if err := yaml.Unmarshal(manifest, &meta); err != nil {
+			return nil, nil, err
+		}
+
+		if meta == (metav1.TypeMeta{}) {
+			continue
+		}
+
+		switch meta.GroupVersionKind() {
+		case argov1alpha1.AppProjectSchemaGroupVersionKind:
+			if appProject != nil {
+				return nil, nil, fmt.Errorf("more than one AppProject found")
+			}
+
+			appProject = &argov1alpha1.AppProject{}
+			if err := yaml.Unmarshal(manifest, appProject); err != nil {
+				return nil, nil, err
+			}
+
+		case argov1alpha1.ApplicationSchemaGroupVersionKind:
+			var app argov1alpha1.Application
+			if err := yaml.Unmarshal(manifest, &app); err != nil {
+				return nil, nil, err
+			}
+			apps = append(apps, app)
+
+		default:
+			return nil, nil, fmt.Errorf("unsupported kind %s", meta.GroupVersionKind())
+		}
+	}
+
+	if appProject == nil {
+		return nil, nil, fmt.Errorf("no AppProject found")
+	}
+
+	return appProject, apps, nil
+}
+
+func convertManifests(appProject *argov1alpha1.AppProject, apps []argov1alpha1.Application) (*ArgoCDProject, error) {
+	argocdProject := &ArgoCDProject{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: argocdProjectAPIVersion,
+			Kind:       argocdProjectKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appProject.Name,
+		},
+	}
+	spec := &argocdProject.Spec
+
+	applicationNamespace, err := convertApplicationNamespace(appProject, apps)
+	if err != nil {
+		return nil, err
+	}
+	spec.ApplicationNamespace = applicationNamespace
+
+	for _, app := range apps {
+		if app.Spec.Project != appProject.Name {
+			return nil, fmt.Errorf("application %s belongs to project %s instead of %s", app.Name, app.Spec.Project, appProject.Name)
+		}
+
+		app.Spec.Project = ""
+		spec.ApplicationTemplates = append(spec.ApplicationTemplates, argov1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        app.Name,
+				Labels:      app.Labels,
+				Annotations: app.Annotations,
+				Finalizers:  app.Finalizers,
+			},
+			Spec: app.Spec,
+		})
+	}
+
+	spec.Environment = convertEnvironment(argocdProject, appProject)
+
+	appProjectSpec := appProject.Spec.DeepCopy()
+
+	appProjectSpec.SourceNamespaces = slices.DeleteFunc(appProjectSpec.SourceNamespaces, func(namespace string) bool {
+		return namespace == spec.ApplicationNamespace
+	})
+	if len(appProjectSpec.SourceNamespaces) == 0 {
+		appProjectSpec.SourceNamespaces = nil
+	}
+
+	switch {
+	case slices.Equal(appProjectSpec.SourceRepos, makeSourceRepos(argocdProject)):
+		appProjectSpec.SourceRepos = nil
+	case slices.Equal(appProjectSpec.SourceRepos, []string{anySourceRepo}):
+		spec.AllowAnySourceRepo = true
+		appProjectSpec.SourceRepos = nil
+	}
+
+	if sameDestinations(appProjectSpec.Destinations, argocdProject.Spec.ApplicationTemplates) {
+		appProjectSpec.Destinations = nil
+	}
+
+	if reflect.DeepEqual(appProjectSpec.NamespaceResourceWhitelist, defaultNamespaceResourceWhitelist) {
+		appProjectSpec.NamespaceResourceWhitelist = nil
+	}
+
+	roles, err := convertProjectRoles(argocdProject, appProjectSpec.Roles)
+	if err != nil {
+		return nil, err
+	}
+	appProjectSpec.Roles = roles
+
+	spec.AppProject = argov1alpha1.AppProject{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      appProject.Labels,
+			Annotations: appProject.Annotations,
+			Finalizers:  appProject.Finalizers,
+		},
+		Spec: *appProjectSpec,
+	}
+
+	return argocdProject, nil
+}
+
+func convertApplicationNamespace(appProject *argov1alpha1.AppProject, apps []argov1alpha1.Application) (string, error) {
+	if len(apps) == 0 {
+		return "", nil
+	}
+
+	namespace := apps[0].Namespace
+	for _, app := range apps {
+		if app.Namespace != namespace {
+			return "", fmt.Errorf("application %s is in namespace %q instead of %q", app.Name, app.Namespace, namespace)
+		}
+	}
+
+	if namespace != "" && !slices.Contains(appProject.Spec.SourceNamespaces, namespace) {
+		return "", fmt.Errorf("application namespace %s is not one of the project source namespaces", namespace)
+	}
+
+	return namespace, nil
+}
+
+// convertEnvironment infers the environment of the applications when every source that would be defaulted follows the
+// default conventions and the environment profile grants are in place, clearing their path and target revision.
+func convertEnvironment(argocdProject *ArgoCDProject, appProject *argov1alpha1.AppProject) string {
+	pathTemplate, err := parseConvention("path", "", defaultPathConvention)
+	if err != nil {
+		return ""
+	}
+
+	targetRevisionTemplate, err := parseConvention("targetRevision", "", defaultTargetRevisionConvention)
+	if err != nil {
+		return ""
+	}
+
+	var environment string
+	var conventionalSources []*argov1alpha1.ApplicationSource
+	for i := range argocdProject.Spec.ApplicationTemplates {
+		app := &argocdProject.Spec.ApplicationTemplates[i]
+		if _, marked := app.Annotations[environmentSourceAnnotation]; marked {
+			return ""
+		}
+
+		sources, err := environmentSources(app)
+		if err != nil {
+			return ""
+		}
+
+		for _, source := range sources {
+			if environment == "" {
+				environment = path.Base(source.Path)
+			}
+
+			data := conventionData{
+				AppName:     app.Name,
+				ProjectName: argocdProject.Name,
+				Environment: environment,
+			}
+
+			sourcePath, err := executeConvention(pathTemplate, data)
+			if err != nil || source.Path != sourcePath {
+				return ""
+			}
+
+			targetRevision, err := executeConvention(targetRevisionTemplate, data)
+			if err != nil || source.TargetRevision != targetRevision {
+				return ""
+			}
+
+			conventionalSources = append(conventionalSources, source)
+		}
+	}
+
+	if environment == "" {
+		return ""
+	}
+
+	environmentProfile := findEnvironmentProfile(defaultEnvironmentProfiles, environment)
+	for _, roleName := range environmentProfile.RoleNames() {
+		role := findProjectRole(appProject, roleName)
+		for _, policy := range environmentProfile.Policies(appProject.Name, argocdProject.Spec.ApplicationNamespace, roleName) {
+			if role == nil || !slices.Contains(role.Policies, policy) {
+				return ""
+			}
+		}
+	}
+
+	for _, source := range conventionalSources {
+		source.Path = ""
+		source.TargetRevision = ""
+	}
+
+	return environment
+}
+
+func sameDestinations(destinations []argov1alpha1.ApplicationDestination, apps []argov1alpha1.Application) bool {
+	destinationMap := make(map[string]struct{})
+	for _, app := range apps {
+		destinationMap[app.Spec.Destination.String()] = struct{}{}
+	}
+
+	if len(destinations) != len(destinationMap) {
+		return false
+	}
+
+	for _, destination := range destinations {
+		if _, ok := destinationMap[destination.String()]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// convertProjectRoles lifts the groups of the built-in roles into spec.accessControl, keeping any extra policy or token
+// in a template role merged into the generated one.
+func convertProjectRoles(argocdProject *ArgoCDProject, roles []argov1alpha1.ProjectRole) ([]argov1alpha1.ProjectRole, error) {
+	var templateRoles []argov1alpha1.ProjectRole
+
+	var environmentProfile *EnvironmentProfile
+	if argocdProject.Spec.Environment != "" {
+		environmentProfile = findEnvironmentProfile(defaultEnvironmentProfiles, argocdProject.Spec.Environment)
+	}
+
+	for _, role := range roles {
+		var groups *[]string
+		var policies []string
+		switch role.Name {
+		case ReadOnly.String():
+			groups = &argocdProject.Spec.AccessControl.ReadOnly
+			policies = ReadOnly.Policies(argocdProject.Name, argocdProject.Spec.ApplicationNamespace)
+		case ReadSync.String():
+			groups = &argocdProject.Spec.AccessControl.ReadSync
+			policies = ReadSync.Policies(argocdProject.Name, argocdProject.Spec.ApplicationNamespace)
+		default:
+			templateRoles = append(templateRoles, role)
+			continue
+		}
+
+		if environmentProfile != nil && slices.Contains(environmentProfile.RoleNames(), role.Name) {
+			policies = append(policies, environmentProfile.Policies(argocdProject.Name, argocdProject.Spec.ApplicationNamespace, role.Name)...)
+		}
+
+		for _, policy := range policies {
+			if !slices.Contains(role.Policies, policy) {
+				return nil, fmt.Errorf("role %s does not grant the built-in policy %q", role.Name, policy)
+			}
+		}
+		*groups = role.Groups
+
+		extraPolicies := slices.DeleteFunc(slices.Clone(role.Policies), func(policy string) bool {
+			return slices.Contains(policies, policy)
+		})
+		if len(extraPolicies) == 0 && len(role.JWTTokens) == 0 && role.Description == "" {
+			continue
+		}
+
+		argocdProject.Spec.MergeTemplateRoles = true
+		templateRoles = append(templateRoles, argov1alpha1.ProjectRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Policies:    extraPolicies,
+			JWTTokens:   role.JWTTokens,
+		})
+	}
+
+	sort.SliceStable(templateRoles, func(i, j int) bool {
+		return templateRoles[i].Name < templateRoles[j].Name
+	})
+
+	return templateRoles, nil
+}
+
+func marshalArgoCDProject(argocdProject *ArgoCDProject) ([]byte, error) {
+	b, err := json.Marshal(argocdProject)
+	if err != nil {
+		return nil, err
+	}
+
+	var vm map[string]interface{}
+	if err := json.Unmarshal(b, &vm); err != nil {
+		return nil, err
+	}
+
+	pruneMetadata(vm)
+
+	spec := vm["spec"].(map[string]interface{})
+	if appProjectTemplate, ok := spec["appProjectTemplate"].(map[string]interface{}); ok {
+		delete(appProjectTemplate, yamlStatusField)
+		pruneMetadata(appProjectTemplate)
+		pruneEmptyFields(appProjectTemplate, "spec")
+	}
+	if applicationTemplates, ok := spec["applicationTemplates"].([]interface{}); ok {
+		for _, applicationTemplate := range applicationTemplates {
+			applicationTemplate := applicationTemplate.(map[string]interface{})
+			delete(applicationTemplate, yamlStatusField)
+			pruneMetadata(applicationTemplate)
+
+			if applicationSpec, ok := applicationTemplate["spec"].(map[string]interface{}); ok && applicationSpec["project"] == "" {
+				delete(applicationSpec, "project")
+			}
+		}
+	}
+	pruneEmptyFields(spec, "accessControl", "applicationDefaults", "conventions", "appProjectTemplate")
+
+	return yaml.Marshal(vm)
+}
+
+func pruneMetadata(object map[string]interface{}) {
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		pruneEmptyFields(metadata, "creationTimestamp")
+	}
+	pruneEmptyFields(object, "metadata")
+}
+
+// pruneEmptyFields deletes the given fields when they are null or empty objects, which struct fields marshal to even
+// when omitempty is set.
+func pruneEmptyFields(object map[string]interface{}, keys ...string) {
+	for _, key := range keys {
+		value, ok := object[key]
+		if !ok {
+			continue
+		}
+
+		if fields, isObject := value.(map[string]interface{}); value == nil || isObject && len(fields) == 0 {
+			delete(object, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type ApplicationDefaults struct {
+	SyncPolicy         *argov1alpha1.SyncPolicy       `json:"syncPolicy,omitempty"`
+	ResourcesFinalizer *bool                          `json:"resourcesFinalizer,omitempty"`
+	IgnoreDifferences  argov1alpha1.IgnoreDifferences `json:"ignoreDifferences,omitempty"`
+}
+
+func (d *ApplicationDefaults) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, ignoreDifference := range d.IgnoreDifferences {
+		ignoreDifferencePath := path.Child("ignoreDifferences").Index(i)
+
+		if ignoreDifference.Kind == "" {
+			allErrs = append(allErrs, field.Required(ignoreDifferencePath.Child("kind"), ""))
+		}
+
+		if len(ignoreDifference.JSONPointers) == 0 && len(ignoreDifference.JQPathExpressions) == 0 && len(ignoreDifference.ManagedFieldsManagers) == 0 {
+			allErrs = append(allErrs, field.Required(ignoreDifferencePath, "either jsonPointers, jqPathExpressions or managedFieldsManagers is required"))
+		}
+	}
+
+	return allErrs
+}
+
+func makeApplicationDefaults(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) *ApplicationDefaults {
+	applicationDefaults := argocdProject.Spec.ApplicationDefaults
+
+	if environmentProfile.ApplicationDefaults.SyncPolicy != nil {
+		applicationDefaults.SyncPolicy = environmentProfile.ApplicationDefaults.SyncPolicy
+	}
+
+	if environmentProfile.ApplicationDefaults.ResourcesFinalizer != nil {
+		applicationDefaults.ResourcesFinalizer = environmentProfile.ApplicationDefaults.ResourcesFinalizer
+	}
+
+	applicationDefaults.IgnoreDifferences = append(append(argov1alpha1.IgnoreDifferences(nil), environmentProfile.ApplicationDefaults.IgnoreDifferences...), argocdProject.Spec.ApplicationDefaults.IgnoreDifferences...)
+
+	return &applicationDefaults
+}
+
+func (d *ApplicationDefaults) apply(finalizers *[]string, spec *argov1alpha1.ApplicationSpec) {
+	if spec.SyncPolicy == nil && d.SyncPolicy != nil {
+		spec.SyncPolicy = d.SyncPolicy.DeepCopy()
+	}
+
+	if *finalizers == nil && d.ResourcesFinalizer != nil && *d.ResourcesFinalizer {
+		*finalizers = []string{
+			argov1alpha1.ResourcesFinalizerName,
+		}
+	}
+
+	for _, ignoreDifference := range d.IgnoreDifferences {
+		if !hasIgnoreDifference(spec.IgnoreDifferences, &ignoreDifference) {
+			spec.IgnoreDifferences = append(spec.IgnoreDifferences, *ignoreDifference.DeepCopy())
+		}
+	}
+}
+
+func hasIgnoreDifference(ignoreDifferences argov1alpha1.IgnoreDifferences, ignoreDifference *argov1alpha1.ResourceIgnoreDifferences) bool {
+	for i := range ignoreDifferences {
+		if reflect.DeepEqual(&ignoreDifferences[i], ignoreDifference) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func makeOrphanedResources(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile, appProject *argov1alpha1.AppProject) *argov1alpha1.OrphanedResourcesMonitorSettings {
+	switch {
+	case appProject.Spec.OrphanedResources != nil:
+		return appProject.Spec.OrphanedResources
+	case environmentProfile.OrphanedResources != nil:
+		return environmentProfile.OrphanedResources.DeepCopy()
+	case argocdProject.Spec.OrphanedResources != nil:
+		return argocdProject.Spec.OrphanedResources.DeepCopy()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	kustomizePluginConfigRootEnv = "KUSTOMIZE_PLUGIN_CONFIG_ROOT"
+
+	stagingEnvironment = "staging"
+
+	environmentActionExec     = "exec"
+	environmentActionOverride = "override"
+
+	policyResourceExec = "exec"
+	policyActionCreate = "create"
+)
+
+var defaultEnvironmentProfiles = []EnvironmentProfile{
+	EnvironmentProfile{
+		Name: stagingEnvironment,
+		Actions: []string{
+			environmentActionOverride,
+		},
+	},
+}
+
+type EnvironmentProfile struct {
+	Name                string                                         `json:"name,omitempty"`
+	Roles               []string                                       `json:"roles,omitempty"`
+	Actions             []string                                       `json:"actions,omitempty"`
+	SyncWindows         []SyncWindow                                   `json:"syncWindows,omitempty"`
+	ApplicationDefaults ApplicationDefaults                            `json:"applicationDefaults,omitempty"`
+	Notifications       []Notification                                 `json:"notifications,omitempty"`
+	Protected           bool                                           `json:"protected,omitempty"`
+	OrphanedResources   *argov1alpha1.OrphanedResourcesMonitorSettings `json:"orphanedResources,omitempty"`
+}
+
+func (p *EnvironmentProfile) Policies(appProjectName string, applicationNamespace string, roleName string) []string {
+	policies := make([]string, 0, len(p.Actions))
+
+	for _, action := range p.Actions {
+		if action == environmentActionExec {
+			policies = append(policies, makePolicy(appProjectName, applicationNamespace, roleName, policyResourceExec, policyActionCreate))
+			continue
+		}
+
+		policies = append(policies, makePolicy(appProjectName, applicationNamespace, roleName, policyResourceApplications, action))
+	}
+
+	return policies
+}
+
+type EnvironmentProfilesFile struct {
+	EnvironmentProfiles []EnvironmentProfile `json:"environmentProfiles,omitempty"`
+}
+
+func loadEnvironmentProfiles(argocdProject *ArgoCDProject) ([]EnvironmentProfile, error) {
+	environmentProfiles := append([]EnvironmentProfile(nil), defaultEnvironmentProfiles...)
+
+	if filePath := argocdProject.Spec.EnvironmentProfilesFile; filePath != "" {
+		data, err := os.ReadFile(resolveFilePath(filePath))
+		if err != nil {
+			return nil, err
+		}
+
+		var environmentProfilesFile EnvironmentProfilesFile
+		if err := yaml.Unmarshal(data, &environmentProfilesFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+
+		var allErrs field.ErrorList
+		for i := range environmentProfilesFile.EnvironmentProfiles {
+			allErrs = append(allErrs, environmentProfilesFile.EnvironmentProfiles[i].Validate(field.NewPath("environmentProfiles").Index(i))...)
+		}
+		if len(allErrs) > 0 {
+			return nil, fmt.Errorf("%s: %w", filePath, allErrs.ToAggregate())
+		}
+
+		environmentProfiles = append(environmentProfiles, environmentProfilesFile.EnvironmentProfiles...)
+	}
+
+	return append(environmentProfiles, argocdProject.Spec.EnvironmentProfiles...), nil
+}
+
+func (p *EnvironmentProfile) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if p.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+	}
+
+	for i := range p.SyncWindows {
+		allErrs = append(allErrs, p.SyncWindows[i].Validate(path.Child("syncWindows").Index(i), nil)...)
+	}
+
+	for i := range p.Notifications {
+		allErrs = append(allErrs, p.Notifications[i].Validate(path.Child("notifications").Index(i))...)
+	}
+
+	allErrs = append(allErrs, p.ApplicationDefaults.Validate(path.Child("applicationDefaults"))...)
+
+	return allErrs
+}
+
+func findEnvironmentProfile(environmentProfiles []EnvironmentProfile, environment string) *EnvironmentProfile {
+	environmentProfile := &EnvironmentProfile{
+		Name: environment,
+	}
+	for _, profile := range environmentProfiles {
+		if profile.Name == environment {
+			*environmentProfile = profile
+		}
+	}
+
+	return environmentProfile
+}
+
+func (p *EnvironmentProfile) RoleNames() []string {
+	if len(p.Roles) == 0 {
+		return []string{
+			ReadSync.String(),
+		}
+	}
+
+	return p.Roles
+}
+
+func grantEnvironmentProfile(environmentProfile *EnvironmentProfile, appProject *argov1alpha1.AppProject, applicationNamespace string) {
+	for _, roleName := range environmentProfile.RoleNames() {
+		if projectRole := findProjectRole(appProject, roleName); projectRole != nil {
+			projectRole.Policies = mergeStrings(projectRole.Policies, environmentProfile.Policies(appProject.Name, applicationNamespace, roleName))
+		}
+	}
+}
+
+func findProjectRole(appProject *argov1alpha1.AppProject, roleName string) *argov1alpha1.ProjectRole {
+	roles := appProject.Spec.Roles
+	for i := len(roles) - 1; i >= 0; i-- {
+		if roles[i].Name == roleName {
+			return &roles[i]
+		}
+	}
+
+	return nil
+}
+
+type ProjectEnvironment struct {
+	Name           string                               `json:"name,omitempty"`
+	Destination    *argov1alpha1.ApplicationDestination `json:"destination,omitempty"`
+	AccessControl  AppProjectAccessControl              `json:"accessControl,omitempty"`
+	TargetRevision string                               `json:"targetRevision,omitempty"`
+}
+
+func expandEnvironments(argocdProject *ArgoCDProject) ([]*ArgoCDProject, error) {
+	if len(argocdProject.Spec.Environments) == 0 {
+		return []*ArgoCDProject{
+			argocdProject,
+		}, nil
+	}
+
+	data, err := json.Marshal(argocdProject)
+	if err != nil {
+		return nil, err
+	}
+
+	argocdProjects := make([]*ArgoCDProject, 0, len(argocdProject.Spec.Environments))
+	for i := range argocdProject.Spec.Environments {
+		var environmentProject ArgoCDProject
+		if err := json.Unmarshal(data, &environmentProject); err != nil {
+			return nil, err
+		}
+
+		argocdProject.Spec.Environments[i].apply(&environmentProject)
+		argocdProjects = append(argocdProjects, &environmentProject)
+	}
+
+	return argocdProjects, nil
+}
+
+func (e *ProjectEnvironment) apply(argocdProject *ArgoCDProject) {
+	appProjectName := argocdProject.Name
+	argocdProject.environmentSuffix = "-" + e.Name
+	argocdProject.Name += argocdProject.environmentSuffix
+
+	spec := &argocdProject.Spec
+	spec.Environment = e.Name
+	spec.Environments = nil
+
+	for _, role := range spec.AppProject.Spec.Roles {
+		for i := range role.Policies {
+			role.Policies[i] = renamePolicyProject(role.Policies[i], appProjectName, argocdProject.Name)
+		}
+	}
+
+	spec.AccessControl.ReadOnly = append(spec.AccessControl.ReadOnly, e.AccessControl.ReadOnly...)
+	spec.AccessControl.ReadSync = append(spec.AccessControl.ReadSync, e.AccessControl.ReadSync...)
+
+	if e.TargetRevision != "" {
+		spec.Conventions.TargetRevision = e.TargetRevision
+	}
+
+	if e.Destination != nil {
+		for i := range spec.ApplicationTemplates {
+			e.applyDestination(&spec.ApplicationTemplates[i].Spec.Destination)
+		}
+	}
+
+	if spec.ApplicationSet != nil {
+		if e.Destination != nil {
+			e.applyDestination(&spec.ApplicationSet.Template.Spec.Destination)
+		}
+
+		if spec.ApplicationSet.Name != "" {
+			spec.ApplicationSet.Name += argocdProject.environmentSuffix
+		}
+	}
+}
+
+func (e *ProjectEnvironment) applyDestination(destination *argov1alpha1.ApplicationDestination) {
+	if e.Destination.Server != "" || e.Destination.Name != "" {
+		destination.Server = e.Destination.Server
+		destination.Name = e.Destination.Name
+	}
+
+	if e.Destination.Namespace != "" {
+		destination.Namespace = e.Destination.Namespace
+	}
+}
+
+func resolveFilePath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+
+	if kustomizationPath, exists := os.LookupEnv(kustomizePluginConfigRootEnv); exists {
+		return filepath.Join(kustomizationPath, filePath)
+	}
+
+	return filePath
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	syncOptionCreateNamespace = "CreateNamespace=true"
+)
+
+type NamespaceMetadata struct {
+	Namespaces  []string          `json:"namespaces,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (m *NamespaceMetadata) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(m.Namespaces) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("namespaces"), "the namespaces owned by the project are required"))
+	}
+
+	for i, namespace := range m.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(path.Child("namespaces").Index(i), namespace, msg))
+		}
+	}
+
+	allErrs = append(allErrs, metav1validation.ValidateLabels(m.Labels, path.Child("labels"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(m.Annotations, path.Child("annotations"))...)
+
+	return allErrs
+}
+
+func (m *NamespaceMetadata) apply(spec *argov1alpha1.ApplicationSpec) {
+	if !slices.Contains(m.Namespaces, spec.Destination.Namespace) {
+		return
+	}
+
+	if spec.SyncPolicy == nil {
+		spec.SyncPolicy = &argov1alpha1.SyncPolicy{}
+	}
+
+	if spec.SyncPolicy.ManagedNamespaceMetadata == nil {
+		managedNamespaceMetadata := argov1alpha1.ManagedNamespaceMetadata{
+			Labels:      m.Labels,
+			Annotations: m.Annotations,
+		}
+		spec.SyncPolicy.ManagedNamespaceMetadata = managedNamespaceMetadata.DeepCopy()
+	}
+
+	spec.SyncPolicy.SyncOptions = spec.SyncPolicy.SyncOptions.AddOption(syncOptionCreateNamespace)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	notificationSubscribeAnnotationPrefix = "notifications.argoproj.io/subscribe."
+	notificationRecipientsSeparator       = ";"
+)
+
+type Notification struct {
+	Trigger    string   `json:"trigger,omitempty"`
+	Service    string   `json:"service,omitempty"`
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+func (n *Notification) annotationKey() string {
+	return notificationSubscribeAnnotationPrefix + n.Trigger + "." + n.Service
+}
+
+func (n *Notification) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if n.Trigger == "" {
+		allErrs = append(allErrs, field.Required(path.Child("trigger"), ""))
+	}
+
+	if n.Service == "" {
+		allErrs = append(allErrs, field.Required(path.Child("service"), ""))
+	}
+
+	if n.Trigger != "" && n.Service != "" {
+		for _, msg := range validation.IsQualifiedName(n.annotationKey()) {
+			allErrs = append(allErrs, field.Invalid(path, n.annotationKey(), msg))
+		}
+	}
+
+	if len(n.Recipients) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("recipients"), ""))
+	}
+
+	return allErrs
+}
+
+func makeNotificationAnnotations(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) map[string]string {
+	notifications := append(append([]Notification(nil), argocdProject.Spec.Notifications...), environmentProfile.Notifications...)
+
+	annotations := make(map[string]string, len(notifications))
+	for i := range notifications {
+		annotations[notifications[i].annotationKey()] = strings.Join(notifications[i].Recipients, notificationRecipientsSeparator)
+	}
+
+	return annotations
+}
+
+func applyNotificationAnnotations(annotations *map[string]string, notificationAnnotations map[string]string) {
+	for key, value := range notificationAnnotations {
+		if _, ok := (*annotations)[key]; ok {
+			continue
+		}
+
+		if *annotations == nil {
+			*annotations = make(map[string]string, len(notificationAnnotations))
+		}
+		(*annotations)[key] = value
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application"
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultParentDestinationServer = "https://kubernetes.default.svc"
+)
+
+type ParentApplicationOutput struct {
+	Name        string                              `json:"name,omitempty"`
+	Source      argov1alpha1.ApplicationSource      `json:"source,omitempty"`
+	Destination argov1alpha1.ApplicationDestination `json:"destination,omitempty"`
+}
+
+func (o *ParentApplicationOutput) destination(applicationNamespace string) argov1alpha1.ApplicationDestination {
+	destination := o.Destination
+
+	if destination.Server == "" && destination.Name == "" {
+		destination.Server = defaultParentDestinationServer
+	}
+
+	if destination.Namespace == "" {
+		destination.Namespace = applicationNamespace
+	}
+
+	return destination
+}
+
+func makeParentApplication(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) ([]byte, error) {
+	parentApplicationOutput := argocdProject.Spec.ParentApplication
+
+	name := parentApplicationOutput.Name
+	if name == "" {
+		name = argocdProject.Name
+	}
+
+	source := parentApplicationOutput.Source
+
+	app := &argov1alpha1.Application{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: argov1alpha1.SchemeGroupVersion.String(),
+			Kind:       application.ApplicationKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: argocdProject.Spec.ApplicationNamespace,
+		},
+		Spec: argov1alpha1.ApplicationSpec{
+			Project:     argocdProject.Name,
+			Source:      &source,
+			Destination: parentApplicationOutput.destination(argocdProject.Spec.ApplicationNamespace),
+		},
+	}
+
+	makeApplicationDefaults(argocdProject, environmentProfile).apply(&app.Finalizers, &app.Spec)
+	applyNotificationAnnotations(&app.Annotations, makeNotificationAnnotations(argocdProject, environmentProfile))
+
+	return marshalYAMLWithoutStatusField(app)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	policySeparator = ","
+
+	policyTypePermission = "p"
+	policyTypeGroup      = "g"
+
+	policyEffectAllow = "allow"
+	policyEffectDeny  = "deny"
+
+	policyActionAll    = "*"
+	policyActionDelete = "delete"
+)
+
+type casbinPolicy struct {
+	Type     string
+	Subject  string
+	Resource string
+	Action   string
+	Object   string
+	Effect   string
+}
+
+func parsePolicy(line string) (*casbinPolicy, error) {
+	fields := strings.Split(line, policySeparator)
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	switch fields[0] {
+	case policyTypePermission:
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("permission policies must have 6 fields, got %d", len(fields))
+		}
+
+		policy := &casbinPolicy{
+			Type:     fields[0],
+			Subject:  fields[1],
+			Resource: fields[2],
+			Action:   fields[3],
+			Object:   fields[4],
+			Effect:   fields[5],
+		}
+		if policy.Resource == "" || policy.Action == "" || policy.Object == "" {
+			return nil, fmt.Errorf("permission policies must have a resource, an action and an object")
+		}
+		if policy.Effect != policyEffectAllow && policy.Effect != policyEffectDeny {
+			return nil, fmt.Errorf("unknown effect %q", policy.Effect)
+		}
+
+		return policy, nil
+
+	case policyTypeGroup:
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("group policies must have 3 fields, got %d", len(fields))
+		}
+
+		return &casbinPolicy{
+			Type:    fields[0],
+			Subject: fields[1],
+			Object:  fields[2],
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown policy type %q", fields[0])
+	}
+}
+
+// lintPolicy parses a policy of a role and checks that it does not reach outside the project.
+func lintPolicy(appProjectName string, roleName string, line string) (*casbinPolicy, error) {
+	policy, err := parsePolicy(line)
+	if err != nil {
+		return nil, err
+	}
+
+	if subject := fmt.Sprintf("proj:%s:%s", appProjectName, roleName); policy.Subject != subject {
+		return nil, fmt.Errorf("subject must be %s", subject)
+	}
+
+	switch policy.Type {
+	case policyTypePermission:
+		if !strings.HasPrefix(policy.Object, appProjectName+"/") {
+			return nil, fmt.Errorf("object %s escapes project %s", policy.Object, appProjectName)
+		}
+
+	case policyTypeGroup:
+		if !strings.HasPrefix(policy.Object, fmt.Sprintf("proj:%s:", appProjectName)) {
+			return nil, fmt.Errorf("role %s escapes project %s", policy.Object, appProjectName)
+		}
+	}
+
+	return policy, nil
+}
+
+func (p *casbinPolicy) grantsDestructiveAction() bool {
+	if p.Type != policyTypePermission || p.Effect != policyEffectAllow {
+		return false
+	}
+
+	deletes := p.Action == policyActionDelete || p.Action == policyActionAll
+	execs := (p.Resource == policyResourceExec || p.Resource == policyResourceAll) && (p.Action == policyActionCreate || p.Action == policyActionAll)
+
+	return deletes || execs
+}
+
+// renamePolicyProject moves a policy of a role from one project to another, leaving malformed policies and the ones
+// not referring to the project untouched.
+func renamePolicyProject(line string, appProjectName string, newAppProjectName string) string {
+	policy, err := parsePolicy(line)
+	if err != nil {
+		return line
+	}
+
+	rolePrefix := fmt.Sprintf("proj:%s:", appProjectName)
+	newRolePrefix := fmt.Sprintf("proj:%s:", newAppProjectName)
+
+	objectPrefix, newObjectPrefix := appProjectName+"/", newAppProjectName+"/"
+	if policy.Type == policyTypeGroup {
+		objectPrefix, newObjectPrefix = rolePrefix, newRolePrefix
+	}
+
+	subject, subjectRenamed := replacePrefix(policy.Subject, rolePrefix, newRolePrefix)
+	object, objectRenamed := replacePrefix(policy.Object, objectPrefix, newObjectPrefix)
+	if !subjectRenamed && !objectRenamed {
+		return line
+	}
+
+	policy.Subject = subject
+	policy.Object = object
+
+	return policy.String()
+}
+
+func replacePrefix(s string, prefix string, newPrefix string) (string, bool) {
+	if rest, found := strings.CutPrefix(s, prefix); found {
+		return newPrefix + rest, true
+	}
+
+	return s, false
+}
+
+func (p *casbinPolicy) String() string {
+	fields := []string{p.Type, p.Subject, p.Resource, p.Action, p.Object, p.Effect}
+	if p.Type == policyTypeGroup {
+		fields = []string{p.Type, p.Subject, p.Object}
+	}
+
+	return strings.Join(fields, policySeparator+" ")
+}
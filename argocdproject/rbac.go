@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultRBACConfigMapName      = "argocd-rbac-cm"
+	defaultRBACConfigMapNamespace = "argocd"
+
+	kustomizeBehaviorAnnotation = "kustomize.config.k8s.io/behavior"
+	kustomizeBehaviorMerge      = "merge"
+)
+
+type RBACConfigMapOutput struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func makeRBACConfigMap(rbacConfigMapOutput *RBACConfigMapOutput, argocdProjects []*ArgoCDProject) ([]byte, error) {
+	name := rbacConfigMapOutput.Name
+	if name == "" {
+		name = defaultRBACConfigMapName
+	}
+
+	namespace := rbacConfigMapOutput.Namespace
+	if namespace == "" {
+		namespace = defaultRBACConfigMapNamespace
+	}
+
+	configMap := corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       reflect.TypeOf(corev1.ConfigMap{}).Name(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				kustomizeBehaviorAnnotation: kustomizeBehaviorMerge,
+			},
+		},
+		Data: make(map[string]string, len(argocdProjects)),
+	}
+
+	for _, argocdProject := range argocdProjects {
+		configMap.Data[fmt.Sprintf("policy.%s.csv", argocdProject.Name)] = makeGroupMappings(argocdProject)
+	}
+
+	return yaml.Marshal(configMap)
+}
+
+func makeGroupMappings(argocdProject *ArgoCDProject) string {
+	roleGroups := map[string][]string{
+		ReadOnly.String(): argocdProject.Spec.AccessControl.ReadOnly,
+		ReadSync.String(): argocdProject.Spec.AccessControl.ReadSync,
+	}
+	for _, accessRole := range argocdProject.Spec.AccessRoles {
+		roleGroups[accessRole.Name] = append(roleGroups[accessRole.Name], accessRole.Groups...)
+	}
+
+	lineMap := make(map[string]struct{})
+	for roleName, groups := range roleGroups {
+		for _, group := range groups {
+			lineMap[fmt.Sprintf("g, %s, proj:%s:%s", group, argocdProject.Name, roleName)] = struct{}{}
+		}
+	}
+
+	lines := make([]string, 0, len(lineMap))
+	for line := range lineMap {
+		lines = append(lines, line+"\n")
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	resourceGroupAll = "*"
+	resourceKindAll  = "*"
+
+	rbacGroupName          = "rbac.authorization.k8s.io"
+	apiextensionsGroupName = "apiextensions.k8s.io"
+	admissionGroupName     = "admissionregistration.k8s.io"
+)
+
+type resourcePreset struct {
+	NamespaceResourceBlacklist []metav1.GroupKind
+	ClusterResourceBlacklist   []metav1.GroupKind
+}
+
+var resourcePresets = map[string]resourcePreset{
+	"no-rbac": resourcePreset{
+		NamespaceResourceBlacklist: []metav1.GroupKind{
+			metav1.GroupKind{
+				Group: rbacGroupName,
+				Kind:  "Role",
+			},
+			metav1.GroupKind{
+				Group: rbacGroupName,
+				Kind:  "RoleBinding",
+			},
+		},
+		ClusterResourceBlacklist: []metav1.GroupKind{
+			metav1.GroupKind{
+				Group: rbacGroupName,
+				Kind:  "ClusterRole",
+			},
+			metav1.GroupKind{
+				Group: rbacGroupName,
+				Kind:  "ClusterRoleBinding",
+			},
+		},
+	},
+	"no-crds": resourcePreset{
+		ClusterResourceBlacklist: []metav1.GroupKind{
+			metav1.GroupKind{
+				Group: apiextensionsGroupName,
+				Kind:  "CustomResourceDefinition",
+			},
+		},
+	},
+	"no-webhooks": resourcePreset{
+		ClusterResourceBlacklist: []metav1.GroupKind{
+			metav1.GroupKind{
+				Group: admissionGroupName,
+				Kind:  "MutatingWebhookConfiguration",
+			},
+			metav1.GroupKind{
+				Group: admissionGroupName,
+				Kind:  "ValidatingWebhookConfiguration",
+			},
+		},
+	},
+}
+
+var defaultNamespaceResourceWhitelist = []metav1.GroupKind{
+	metav1.GroupKind{
+		Group: resourceGroupAll,
+		Kind:  resourceKindAll,
+	},
+}
+
+func applyResourcePolicy(argocdProject *ArgoCDProject, appProject *argov1alpha1.AppProject) error {
+	if len(appProject.Spec.NamespaceResourceWhitelist) == 0 {
+		appProject.Spec.NamespaceResourceWhitelist = append([]metav1.GroupKind(nil), defaultNamespaceResourceWhitelist...)
+	}
+
+	for _, presetName := range argocdProject.Spec.ResourcePresets {
+		preset, ok := resourcePresets[presetName]
+		if !ok {
+			return fmt.Errorf("unknown resource preset %s", presetName)
+		}
+
+		appProject.Spec.NamespaceResourceBlacklist = mergeGroupKinds(appProject.Spec.NamespaceResourceBlacklist, preset.NamespaceResourceBlacklist)
+		appProject.Spec.ClusterResourceBlacklist = mergeGroupKinds(appProject.Spec.ClusterResourceBlacklist, preset.ClusterResourceBlacklist)
+	}
+
+	return nil
+}
+
+func mergeGroupKinds(groupKinds []metav1.GroupKind, others []metav1.GroupKind) []metav1.GroupKind {
+	seen := make(map[metav1.GroupKind]bool, len(groupKinds))
+	for _, groupKind := range groupKinds {
+		seen[groupKind] = true
+	}
+
+	for _, groupKind := range others {
+		if !seen[groupKind] {
+			seen[groupKind] = true
+			groupKinds = append(groupKinds, groupKind)
+		}
+	}
+
+	return groupKinds
+}
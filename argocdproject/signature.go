@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var signatureKeyIDRegexp = regexp.MustCompile("^[0-9A-Fa-f]{16}$")
+
+func validateSignatureKeys(signatureKeys []string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	keyIDs := make(map[string]bool, len(signatureKeys))
+	for i, keyID := range signatureKeys {
+		switch {
+		case !signatureKeyIDRegexp.MatchString(keyID):
+			allErrs = append(allErrs, field.Invalid(path.Index(i), keyID, "must be a 16 hexadecimal digit key ID"))
+		case keyIDs[keyID]:
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), keyID))
+		}
+		keyIDs[keyID] = true
+	}
+
+	return allErrs
+}
+
+func makeSignatureKeys(argocdProject *ArgoCDProject, appProject *argov1alpha1.AppProject) []argov1alpha1.SignatureKey {
+	signatureKeys := appProject.Spec.SignatureKeys
+
+	for _, keyID := range argocdProject.Spec.SignatureKeys {
+		if !hasSignatureKey(signatureKeys, keyID) {
+			signatureKeys = append(signatureKeys, argov1alpha1.SignatureKey{
+				KeyID: keyID,
+			})
+		}
+	}
+
+	return signatureKeys
+}
+
+func hasSignatureKey(signatureKeys []argov1alpha1.SignatureKey, keyID string) bool {
+	for _, signatureKey := range signatureKeys {
+		if signatureKey.KeyID == keyID {
+			return true
+		}
+	}
+
+	return false
+}
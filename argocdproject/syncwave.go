@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+const (
+	dependsOnAnnotation = "argocdproject.incognia.com/depends-on"
+	syncWaveAnnotation  = "argocd.argoproj.io/sync-wave"
+
+	dependsOnSeparator = ","
+)
+
+func dependencyNames(app *argov1alpha1.Application) []string {
+	value, ok := app.Annotations[dependsOnAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, dependsOnSeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// makeSyncWaves returns the wave of every application taking part in a dependency, which is one past the highest wave
+// among the applications it depends on.
+func makeSyncWaves(apps []argov1alpha1.Application) (map[string]int, error) {
+	dependencies := make(map[string][]string, len(apps))
+	for i := range apps {
+		names := dependencyNames(&apps[i])
+		if len(names) == 0 {
+			continue
+		}
+
+		dependencies[apps[i].Name] = names
+		for _, name := range names {
+			if _, ok := dependencies[name]; !ok {
+				dependencies[name] = nil
+			}
+		}
+	}
+
+	waves := make(map[string]int, len(dependencies))
+	visiting := make(map[string]bool, len(dependencies))
+
+	var visit func(name string, path []string) (int, error)
+	visit = func(name string, path []string) (int, error) {
+		if wave, ok := waves[name]; ok {
+			return wave, nil
+		}
+
+		path = append(path, name)
+		if visiting[name] {
+			return 0, fmt.Errorf("dependency cycle %s", strings.Join(path, " -> "))
+		}
+		visiting[name] = true
+
+		wave := 0
+		for _, dependencyName := range dependencies[name] {
+			dependencyWave, err := visit(dependencyName, path)
+			if err != nil {
+				return 0, err
+			}
+
+			if dependencyWave+1 > wave {
+				wave = dependencyWave + 1
+			}
+		}
+
+		waves[name] = wave
+		return wave, nil
+	}
+
+	for i := range apps {
+		if _, ok := dependencies[apps[i].Name]; !ok {
+			continue
+		}
+
+		if _, err := visit(apps[i].Name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return waves, nil
+}
+
+func applySyncWave(app *argov1alpha1.Application, waves map[string]int) {
+	popAnnotation(&app.ObjectMeta, dependsOnAnnotation)
+
+	wave, ok := waves[app.Name]
+	if !ok {
+		return
+	}
+
+	if app.Annotations == nil {
+		app.Annotations = make(map[string]string, 1)
+	}
+	app.Annotations[syncWaveAnnotation] = strconv.Itoa(wave)
+}
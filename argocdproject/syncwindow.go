@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	syncWindowAllApplications = "*"
+)
+
+type SyncWindow struct {
+	Kind         string   `json:"kind,omitempty"`
+	Schedule     string   `json:"schedule,omitempty"`
+	Duration     string   `json:"duration,omitempty"`
+	TimeZone     string   `json:"timeZone,omitempty"`
+	Applications []string `json:"applications,omitempty"`
+	ManualSync   bool     `json:"manualSync,omitempty"`
+}
+
+func (w *SyncWindow) Validate(path *field.Path, appNames map[string]bool) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if w.TimeZone != "" {
+		if _, err := time.LoadLocation(w.TimeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("timeZone"), w.TimeZone, err.Error()))
+		}
+	}
+
+	if appNames != nil {
+		for i, application := range w.Applications {
+			if !appNames[application] {
+				allErrs = append(allErrs, field.NotFound(path.Child("applications").Index(i), application))
+			}
+		}
+	}
+
+	// the time zone is validated above, as Argo CD falls back to UTC instead of failing
+	argoSyncWindow := w.toArgo()
+	argoSyncWindow.TimeZone = ""
+	if err := argoSyncWindow.Validate(); err != nil {
+		allErrs = append(allErrs, field.Invalid(path, w.Schedule, err.Error()))
+	}
+
+	return allErrs
+}
+
+func makeSyncWindows(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile) argov1alpha1.SyncWindows {
+	syncWindows := append(append([]SyncWindow(nil), environmentProfile.SyncWindows...), argocdProject.Spec.SyncWindows...)
+
+	argoSyncWindows := make(argov1alpha1.SyncWindows, 0, len(syncWindows))
+	for i := range syncWindows {
+		argoSyncWindow := syncWindows[i].toArgo()
+		for j, application := range argoSyncWindow.Applications {
+			if application != syncWindowAllApplications {
+				argoSyncWindow.Applications[j] = application + argocdProject.environmentSuffix
+			}
+		}
+		argoSyncWindows = append(argoSyncWindows, argoSyncWindow)
+	}
+
+	return argoSyncWindows
+}
+
+func (w *SyncWindow) toArgo() *argov1alpha1.SyncWindow {
+	applications := append([]string(nil), w.Applications...)
+	if len(applications) == 0 {
+		applications = []string{
+			syncWindowAllApplications,
+		}
+	}
+
+	return &argov1alpha1.SyncWindow{
+		Kind:         w.Kind,
+		Schedule:     w.Schedule,
+		Duration:     w.Duration,
+		Applications: applications,
+		ManualSync:   w.ManualSync,
+		TimeZone:     w.TimeZone,
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+
+	argov1alpha1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var supportedApplicationSetGenerators = []string{
+	applicationSetGeneratorList,
+	applicationSetGeneratorGit,
+}
+
+func validateArgoCDProject(argocdProject *ArgoCDProject, environmentProfiles []EnvironmentProfile, clusterCatalog clusterCatalog) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if argocdProject.Name == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("metadata", "name"), ""))
+	}
+
+	specPath := field.NewPath("spec")
+	spec := &argocdProject.Spec
+
+	appNames := make(map[string]bool, len(spec.ApplicationTemplates))
+	for _, app := range spec.ApplicationTemplates {
+		appNames[app.Name] = true
+	}
+
+	if namespace := spec.ApplicationNamespace; namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("applicationNamespace"), namespace, msg))
+		}
+	}
+
+	allErrs = append(allErrs, validateAccessRoles(argocdProject, specPath.Child("accessRoles"))...)
+
+	argocdProjects, err := expandEnvironments(argocdProject)
+	if err != nil {
+		allErrs = append(allErrs, field.InternalError(specPath.Child("environments"), err))
+	}
+	allErrs = append(allErrs, validateTemplateRoles(argocdProject, argocdProjects, specPath.Child("appProjectTemplate", "spec", "roles"))...)
+
+	if spec.Environment != "" {
+		allErrs = append(allErrs, validateEnvironment(argocdProject, environmentProfiles, spec.Environment, appNames, specPath.Child("environment"))...)
+	}
+	allErrs = append(allErrs, validateEnvironments(argocdProject, environmentProfiles, clusterCatalog, appNames, specPath)...)
+	allErrs = append(allErrs, validateConventions(&spec.Conventions, specPath.Child("conventions"))...)
+	allErrs = append(allErrs, spec.ApplicationDefaults.Validate(specPath.Child("applicationDefaults"))...)
+
+	for i := range spec.EnvironmentProfiles {
+		allErrs = append(allErrs, spec.EnvironmentProfiles[i].Validate(specPath.Child("environmentProfiles").Index(i))...)
+	}
+
+	for i, presetName := range spec.ResourcePresets {
+		if _, ok := resourcePresets[presetName]; !ok {
+			allErrs = append(allErrs, field.NotFound(specPath.Child("resourcePresets").Index(i), presetName))
+		}
+	}
+
+	for i := range spec.SyncWindows {
+		allErrs = append(allErrs, spec.SyncWindows[i].Validate(specPath.Child("syncWindows").Index(i), appNames)...)
+	}
+
+	allErrs = append(allErrs, validateSignatureKeys(spec.SignatureKeys, specPath.Child("signatureKeys"))...)
+
+	for i := range spec.Notifications {
+		allErrs = append(allErrs, spec.Notifications[i].Validate(specPath.Child("notifications").Index(i))...)
+	}
+
+	if spec.NamespaceMetadata != nil {
+		allErrs = append(allErrs, spec.NamespaceMetadata.Validate(specPath.Child("namespaceMetadata"))...)
+	}
+
+	allErrs = append(allErrs, validateApplicationTemplates(argocdProject, clusterCatalog, specPath.Child("applicationTemplates"))...)
+
+	if spec.ApplicationSet != nil {
+		allErrs = append(allErrs, validateApplicationSet(argocdProject, specPath)...)
+
+		templateDestinationPath := specPath.Child("applicationSet", "template", "spec", "destination")
+		allErrs = append(allErrs, clusterCatalog.validateDestination(&spec.ApplicationSet.Template.Spec.Destination, templateDestinationPath)...)
+	}
+
+	if spec.ParentApplication != nil {
+		allErrs = append(allErrs, validateParentApplication(argocdProject, clusterCatalog, appNames, specPath)...)
+	}
+
+	return allErrs
+}
+
+func validateParentApplication(argocdProject *ArgoCDProject, clusterCatalog clusterCatalog, appNames map[string]bool, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	parentApplicationOutput := argocdProject.Spec.ParentApplication
+	path := specPath.Child("parentApplication")
+
+	if len(argocdProject.Spec.Environments) > 0 {
+		allErrs = append(allErrs, field.Forbidden(path, "may not be set along with environments"))
+	}
+
+	name := parentApplicationOutput.Name
+	if name == "" {
+		name = argocdProject.Name
+	}
+	if appNames[name] {
+		allErrs = append(allErrs, field.Duplicate(path.Child("name"), name))
+	}
+
+	if parentApplicationOutput.Source.RepoURL == "" {
+		allErrs = append(allErrs, field.Required(path.Child("source", "repoURL"), ""))
+	}
+
+	if parentApplicationOutput.Source.Path == "" {
+		allErrs = append(allErrs, field.Required(path.Child("source", "path"), "the directory holding the generated applications is required"))
+	}
+
+	destinationPath := path.Child("destination")
+	allErrs = append(allErrs, clusterCatalog.validateDestination(&parentApplicationOutput.Destination, destinationPath)...)
+
+	destination := parentApplicationOutput.destination(argocdProject.Spec.ApplicationNamespace)
+	if destination.Namespace == "" {
+		allErrs = append(allErrs, field.Required(destinationPath.Child("namespace"), "required when spec.applicationNamespace is not set"))
+	}
+	if len(argocdProject.Spec.AppProject.Spec.Destinations) == 0 {
+		allErrs = append(allErrs, field.Invalid(destinationPath, destination.String(), "parent application destinations require spec.appProjectTemplate.spec.destinations"))
+	}
+
+	return allErrs
+}
+
+func validateAccessRoles(argocdProject *ArgoCDProject, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	roleNames := generatedRoleNames(argocdProject)
+
+	accessRoleNames := make(map[string]bool, len(argocdProject.Spec.AccessRoles))
+	for i, accessRole := range argocdProject.Spec.AccessRoles {
+		rolePath := path.Index(i)
+
+		switch {
+		case accessRole.Name == "":
+			allErrs = append(allErrs, field.Required(rolePath.Child("name"), ""))
+		case accessRoleNames[accessRole.Name]:
+			allErrs = append(allErrs, field.Duplicate(rolePath.Child("name"), accessRole.Name))
+		case accessRole.Name == ReadOnly.String() || accessRole.Name == ReadSync.String() || accessRole.Name == CISync.String():
+			allErrs = append(allErrs, field.Invalid(rolePath.Child("name"), accessRole.Name, "conflicts with a built-in role"))
+		}
+		accessRoleNames[accessRole.Name] = true
+
+		for j, parentRoleName := range accessRole.Inherits {
+			if !roleNames[parentRoleName] {
+				allErrs = append(allErrs, field.NotFound(rolePath.Child("inherits").Index(j), parentRoleName))
+			}
+		}
+
+		for j, permission := range accessRole.Permissions {
+			if len(permission.Actions) == 0 {
+				allErrs = append(allErrs, field.Required(rolePath.Child("permissions").Index(j).Child("actions"), ""))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateTemplateRoles(argocdProject *ArgoCDProject, argocdProjects []*ArgoCDProject, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	roleNames := generatedRoleNames(argocdProject)
+
+	templateRoleNames := make(map[string]bool, len(argocdProject.Spec.AppProject.Spec.Roles))
+	for i, role := range argocdProject.Spec.AppProject.Spec.Roles {
+		namePath := path.Index(i).Child("name")
+
+		switch {
+		case role.Name == "":
+			allErrs = append(allErrs, field.Required(namePath, ""))
+		case templateRoleNames[role.Name]:
+			allErrs = append(allErrs, field.Duplicate(namePath, role.Name))
+		case roleNames[role.Name] && !argocdProject.Spec.MergeTemplateRoles:
+			allErrs = append(allErrs, field.Invalid(namePath, role.Name, "conflicts with a generated role, set spec.mergeTemplateRoles to merge them"))
+		}
+		templateRoleNames[role.Name] = true
+
+		for j, policy := range role.Policies {
+			for _, environmentProject := range argocdProjects {
+				environmentPolicy := environmentProject.Spec.AppProject.Spec.Roles[i].Policies[j]
+				if _, err := lintPolicy(environmentProject.Name, role.Name, environmentPolicy); err != nil {
+					allErrs = append(allErrs, field.Invalid(path.Index(i).Child("policies").Index(j), policy, err.Error()))
+					break
+				}
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateEnvironments(argocdProject *ArgoCDProject, environmentProfiles []EnvironmentProfile, clusterCatalog clusterCatalog, appNames map[string]bool, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	environments := argocdProject.Spec.Environments
+	path := specPath.Child("environments")
+
+	if len(environments) > 0 && argocdProject.Spec.Environment != "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("environment"), "may not be set along with environments"))
+	}
+
+	environmentNames := make(map[string]bool, len(environments))
+	for i, environment := range environments {
+		environmentPath := path.Index(i)
+		namePath := environmentPath.Child("name")
+
+		if environment.Destination != nil {
+			allErrs = append(allErrs, clusterCatalog.validateDestination(environment.Destination, environmentPath.Child("destination"))...)
+		}
+
+		if _, err := parseConvention("targetRevision", environment.TargetRevision, defaultTargetRevisionConvention); err != nil {
+			allErrs = append(allErrs, field.Invalid(environmentPath.Child("targetRevision"), environment.TargetRevision, err.Error()))
+		}
+
+		switch {
+		case environment.Name == "":
+			allErrs = append(allErrs, field.Required(namePath, ""))
+			continue
+		case environmentNames[environment.Name]:
+			allErrs = append(allErrs, field.Duplicate(namePath, environment.Name))
+		}
+		environmentNames[environment.Name] = true
+
+		allErrs = append(allErrs, validateEnvironment(argocdProject, environmentProfiles, environment.Name, appNames, namePath)...)
+	}
+
+	return allErrs
+}
+
+func validateEnvironment(argocdProject *ArgoCDProject, environmentProfiles []EnvironmentProfile, environment string, appNames map[string]bool, environmentPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if argocdProject.Spec.EnvironmentProfilesFile != "" || len(argocdProject.Spec.EnvironmentProfiles) > 0 {
+		environmentNames := make([]string, 0, len(environmentProfiles))
+		for _, environmentProfile := range environmentProfiles {
+			environmentNames = append(environmentNames, environmentProfile.Name)
+		}
+
+		if !slices.Contains(environmentNames, environment) {
+			allErrs = append(allErrs, field.NotSupported(environmentPath, environment, environmentNames))
+		}
+	}
+
+	environmentProfile := findEnvironmentProfile(environmentProfiles, environment)
+
+	if environmentProfile.Protected {
+		allErrs = append(allErrs, validateProtectedEnvironment(argocdProject, environmentProfile, environmentPath)...)
+	}
+
+	roleNames := generatedRoleNames(argocdProject)
+	for _, role := range argocdProject.Spec.AppProject.Spec.Roles {
+		roleNames[role.Name] = true
+	}
+
+	for _, roleName := range environmentProfile.RoleNames() {
+		if !roleNames[roleName] {
+			allErrs = append(allErrs, field.Invalid(environmentPath, environment, "environment profile grants actions to unknown role "+roleName))
+		}
+	}
+
+	for _, syncWindow := range environmentProfile.SyncWindows {
+		for _, application := range syncWindow.Applications {
+			if !appNames[application] {
+				allErrs = append(allErrs, field.Invalid(environmentPath, environment, "environment profile sync window targets unknown application "+application))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateProtectedEnvironment(argocdProject *ArgoCDProject, environmentProfile *EnvironmentProfile, environmentPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	detail := "environment " + environmentProfile.Name + " is protected"
+
+	if len(argocdProject.Spec.SignatureKeys) == 0 && len(argocdProject.Spec.AppProject.Spec.SignatureKeys) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("signatureKeys"), detail))
+	}
+
+	for _, roleName := range environmentProfile.RoleNames() {
+		for _, line := range environmentProfile.Policies(argocdProject.Name, argocdProject.Spec.ApplicationNamespace, roleName) {
+			policy, err := parsePolicy(line)
+			if err == nil && policy.grantsDestructiveAction() {
+				allErrs = append(allErrs, field.Forbidden(environmentPath, detail+", its profile may not grant delete or exec to "+roleName))
+				break
+			}
+		}
+	}
+
+	for i, role := range argocdProject.Spec.AppProject.Spec.Roles {
+		for j, line := range role.Policies {
+			policy, err := parsePolicy(line)
+			if err == nil && policy.grantsDestructiveAction() {
+				policyPath := specPath.Child("appProjectTemplate", "spec", "roles").Index(i).Child("policies").Index(j)
+				allErrs = append(allErrs, field.Forbidden(policyPath, detail+", delete and exec may not be granted"))
+			}
+		}
+	}
+
+	for i := range argocdProject.Spec.AccessRoles {
+		accessRole := &argocdProject.Spec.AccessRoles[i]
+
+		for _, line := range accessRole.Policies(argocdProject.Name, argocdProject.Spec.ApplicationNamespace) {
+			policy, err := parsePolicy(line)
+			if err == nil && policy.grantsDestructiveAction() {
+				allErrs = append(allErrs, field.Forbidden(specPath.Child("accessRoles").Index(i).Child("permissions"), detail+", delete and exec may not be granted"))
+				break
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateConventions(conventions *SourceConventions, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, err := parseConvention("path", conventions.Path, defaultPathConvention); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("path"), conventions.Path, err.Error()))
+	}
+
+	if _, err := parseConvention("targetRevision", conventions.TargetRevision, defaultTargetRevisionConvention); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("targetRevision"), conventions.TargetRevision, err.Error()))
+	}
+
+	return allErrs
+}
+
+func validateApplicationTemplates(argocdProject *ArgoCDProject, clusterCatalog clusterCatalog, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	appNames := make(map[string]bool, len(argocdProject.Spec.ApplicationTemplates))
+	for i := range argocdProject.Spec.ApplicationTemplates {
+		app := &argocdProject.Spec.ApplicationTemplates[i]
+		appPath := path.Index(i)
+
+		switch {
+		case app.Name == "":
+			allErrs = append(allErrs, field.Required(appPath.Child("metadata", "name"), ""))
+		case appNames[app.Name]:
+			allErrs = append(allErrs, field.Duplicate(appPath.Child("metadata", "name"), app.Name))
+		}
+		appNames[app.Name] = true
+
+		allErrs = append(allErrs, validateApplicationSpec(app, appPath)...)
+		allErrs = append(allErrs, clusterCatalog.validateDestination(&app.Spec.Destination, appPath.Child("spec", "destination"))...)
+	}
+
+	allErrs = append(allErrs, validateDependencies(argocdProject.Spec.ApplicationTemplates, appNames, path)...)
+
+	return allErrs
+}
+
+func validateDependencies(apps []argov1alpha1.Application, appNames map[string]bool, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	dependencyApps := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		for _, name := range dependencyNames(&app) {
+			dependencyApps[app.Name] = true
+			dependencyApps[name] = true
+		}
+	}
+
+	for i := range apps {
+		app := &apps[i]
+		annotationsPath := path.Index(i).Child("metadata", "annotations")
+
+		for _, name := range dependencyNames(app) {
+			if !appNames[name] || name == app.Name {
+				allErrs = append(allErrs, field.NotFound(annotationsPath.Key(dependsOnAnnotation), name))
+			}
+		}
+
+		if _, ok := app.Annotations[syncWaveAnnotation]; ok && dependencyApps[app.Name] {
+			allErrs = append(allErrs, field.Forbidden(annotationsPath.Key(syncWaveAnnotation), "may not be set on applications with dependencies"))
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return allErrs
+	}
+
+	if _, err := makeSyncWaves(apps); err != nil {
+		allErrs = append(allErrs, field.Invalid(path, nil, err.Error()))
+	}
+
+	return allErrs
+}
+
+func validateApplicationSpec(app *argov1alpha1.Application, appPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := appPath.Child("spec")
+
+	if app.Spec.Destination.Server == "" && app.Spec.Destination.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("destination"), "either server or name is required"))
+	}
+
+	if app.Spec.Source == nil && !app.Spec.HasMultipleSources() {
+		allErrs = append(allErrs, field.Required(specPath.Child("source"), "either source or sources is required"))
+	}
+
+	for i, source := range app.Spec.GetSources() {
+		if source.RepoURL != "" {
+			continue
+		}
+
+		if app.Spec.HasMultipleSources() {
+			allErrs = append(allErrs, field.Required(specPath.Child("sources").Index(i).Child("repoURL"), ""))
+		} else {
+			allErrs = append(allErrs, field.Required(specPath.Child("source", "repoURL"), ""))
+		}
+	}
+
+	if environmentSource, ok := app.Annotations[environmentSourceAnnotation]; ok && app.Spec.HasMultipleSources() {
+		found := false
+		for i, source := range app.Spec.Sources {
+			if environmentSource == source.Ref || environmentSource == strconv.Itoa(i) {
+				found = true
+			}
+		}
+
+		if !found {
+			allErrs = append(allErrs, field.NotFound(appPath.Child("metadata", "annotations").Key(environmentSourceAnnotation), environmentSource))
+		}
+	}
+
+	return allErrs
+}
+
+// validateListGeneratorElement rejects the fields of an application template that a list generator element can not
+// carry, since the generated applications would silently lose them.
+func validateListGeneratorElement(app *argov1alpha1.Application, appPath *field.Path) field.ErrorList {
+	var paths []*field.Path
+
+	metadataPath := appPath.Child("metadata")
+	if len(app.Labels) > 0 {
+		paths = append(paths, metadataPath.Child("labels"))
+	}
+
+	annotationKeys := make([]string, 0, len(app.Annotations))
+	for key := range app.Annotations {
+		if key != environmentSourceAnnotation && key != dependsOnAnnotation {
+			annotationKeys = append(annotationKeys, key)
+		}
+	}
+	sort.Strings(annotationKeys)
+	for _, key := range annotationKeys {
+		paths = append(paths, metadataPath.Child("annotations").Key(key))
+	}
+
+	if len(app.Finalizers) > 0 {
+		paths = append(paths, metadataPath.Child("finalizers"))
+	}
+
+	specPath := appPath.Child("spec")
+	if source := app.Spec.Source; source != nil {
+		sourcePath := specPath.Child("source")
+		if source.Helm != nil {
+			paths = append(paths, sourcePath.Child("helm"))
+		}
+		if source.Kustomize != nil {
+			paths = append(paths, sourcePath.Child("kustomize"))
+		}
+		if source.Directory != nil {
+			paths = append(paths, sourcePath.Child("directory"))
+		}
+		if source.Plugin != nil {
+			paths = append(paths, sourcePath.Child("plugin"))
+		}
+	}
+
+	if app.Spec.SyncPolicy != nil {
+		paths = append(paths, specPath.Child("syncPolicy"))
+	}
+	if len(app.Spec.IgnoreDifferences) > 0 {
+		paths = append(paths, specPath.Child("ignoreDifferences"))
+	}
+	if len(app.Spec.Info) > 0 {
+		paths = append(paths, specPath.Child("info"))
+	}
+	if app.Spec.RevisionHistoryLimit != nil {
+		paths = append(paths, specPath.Child("revisionHistoryLimit"))
+	}
+
+	var allErrs field.ErrorList
+	for _, path := range paths {
+		allErrs = append(allErrs, field.Forbidden(path, "not supported by the list generator, set it on spec.applicationSet.template instead"))
+	}
+
+	return allErrs
+}
+
+func validateApplicationSet(argocdProject *ArgoCDProject, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	applicationSetOutput := argocdProject.Spec.ApplicationSet
+	path := specPath.Child("applicationSet")
+
+	switch applicationSetOutput.Generator {
+	case "", applicationSetGeneratorList:
+		for i, app := range argocdProject.Spec.ApplicationTemplates {
+			appPath := specPath.Child("applicationTemplates").Index(i)
+
+			if app.Spec.HasMultipleSources() {
+				allErrs = append(allErrs, field.Forbidden(appPath.Child("spec", "sources"), "multiple sources are not supported by the list generator"))
+			}
+
+			if _, ok := app.Annotations[dependsOnAnnotation]; ok {
+				allErrs = append(allErrs, field.Forbidden(appPath.Child("metadata", "annotations").Key(dependsOnAnnotation), "dependencies are not supported by the list generator"))
+			}
+
+			allErrs = append(allErrs, validateListGeneratorElement(&app, appPath)...)
+		}
+
+		if argocdProject.Spec.NamespaceMetadata != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("namespaceMetadata"), "namespace metadata is not supported by the list generator"))
+		}
+
+	case applicationSetGeneratorGit:
+		if applicationSetOutput.Git == nil {
+			allErrs = append(allErrs, field.Required(path.Child("git"), "git generator settings are required"))
+		}
+
+		if _, ok := applicationSetOutput.Template.Annotations[dependsOnAnnotation]; ok {
+			allErrs = append(allErrs, field.Forbidden(path.Child("template", "metadata", "annotations").Key(dependsOnAnnotation), "dependencies are not supported by the git generator"))
+		}
+
+		destination := &applicationSetOutput.Template.Spec.Destination
+		destinationPath := path.Child("template", "spec", "destination")
+		switch {
+		case destination.Server == "" && destination.Name == "":
+			allErrs = append(allErrs, field.Required(destinationPath, "either server or name is required"))
+		case isTemplatedDestination(destination) && len(argocdProject.Spec.AppProject.Spec.Destinations) == 0:
+			allErrs = append(allErrs, field.Invalid(destinationPath, destination.String(), "templated destinations require spec.appProjectTemplate.spec.destinations"))
+		}
+
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("generator"), applicationSetOutput.Generator, supportedApplicationSetGenerators))
+	}
+
+	return allErrs
+}
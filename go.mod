@@ -9,6 +9,7 @@ require (
 	github.com/onsi/ginkgo/v2 v2.11.0
 	github.com/onsi/gomega v1.27.10
 	k8s.io/api v0.24.17
+	k8s.io/apiextensions-apiserver v0.24.2
 	k8s.io/apimachinery v0.24.17
 	k8s.io/client-go v0.24.17
 	sigs.k8s.io/kustomize/api v0.11.5
@@ -124,7 +125,6 @@ require (
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	k8s.io/apiextensions-apiserver v0.24.2 // indirect
 	k8s.io/apiserver v0.24.17 // indirect
 	k8s.io/cli-runtime v0.24.17 // indirect
 	k8s.io/component-base v0.24.17 // indirect